@@ -0,0 +1,77 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterScheduledPodAutoscalerSpec defines the desired state of ClusterScheduledPodAutoscaler
+type ClusterScheduledPodAutoscalerSpec struct {
+	// label selector of the namespaces the schedule applies to,
+	// Note (this should default to every namespace) :
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Template field for ClusterScheduledPodAutoscaler - the SPA created in every selected namespace:
+	// spec.resource.selector selects the resources to scale in each of them:
+	Template ScheduledPodAutoscalerSpec `json:"template"`
+}
+
+// ClusterScheduledPodAutoscalerStatus defines the observed state of ClusterScheduledPodAutoscaler
+type ClusterScheduledPodAutoscalerStatus struct {
+	// Namespaces currently selected - each of them holds an SPA created from the template.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Conditions of the ClusterScheduledPodAutoscaler - Conflict is True while a selected namespace holds
+	// an SPA of the same name that wasn't created from it.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ConditionConflict is True while SPAs of selected namespaces can't be created from the ClusterScheduledPodAutoscaler
+// because SPAs of the same name already exist there.
+const ConditionConflict = "Conflict"
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=cspa
+// +kubebuilder:subresource:status
+
+// ClusterScheduledPodAutoscaler is the Schema for the clusterscheduledpodautoscalers API
+type ClusterScheduledPodAutoscaler struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterScheduledPodAutoscalerSpec   `json:"spec,omitempty"`
+	Status ClusterScheduledPodAutoscalerStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterScheduledPodAutoscalerList contains a list of ClusterScheduledPodAutoscaler
+type ClusterScheduledPodAutoscalerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterScheduledPodAutoscaler `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterScheduledPodAutoscaler{}, &ClusterScheduledPodAutoscalerList{})
+}
@@ -0,0 +1,85 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var clusterscheduledpodautoscalerlog = logf.Log.WithName("clusterscheduledpodautoscaler-resource")
+
+func (r *ClusterScheduledPodAutoscaler) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-autoscaling-spa-sarmadabualkaz-io-v1-clusterscheduledpodautoscaler,mutating=false,failurePolicy=fail,groups=autoscaling.spa.sarmadabualkaz.io,resources=clusterscheduledpodautoscalers,versions=v1,name=vclusterscheduledpodautoscaler.kb.io
+
+var _ webhook.Validator = &ClusterScheduledPodAutoscaler{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterScheduledPodAutoscaler) ValidateCreate() error {
+	clusterscheduledpodautoscalerlog.Info("validate create", "name", r.Name)
+	return r.validateClusterScheduledPodAutoscaler()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterScheduledPodAutoscaler) ValidateUpdate(old runtime.Object) error {
+	clusterscheduledpodautoscalerlog.Info("validate update", "name", r.Name)
+	return r.validateClusterScheduledPodAutoscaler()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterScheduledPodAutoscaler) ValidateDelete() error {
+	return nil
+}
+
+// validateClusterScheduledPodAutoscaler checks the template like the spec of an SPA (defaulted as the SPAs created from
+// it are) - otherwise an invalid template is only rejected namespace by namespace when the SPAs are created.
+func (r *ClusterScheduledPodAutoscaler) validateClusterScheduledPodAutoscaler() error {
+	var allErrs field.ErrorList
+	if _, err := metav1.LabelSelectorAsSelector(r.Spec.NamespaceSelector); err != nil {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("namespaceSelector"), r.Spec.NamespaceSelector, err.Error()))
+	}
+
+	spa := &ScheduledPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: r.Name}, Spec: *r.Spec.Template.DeepCopy()}
+	spa.Default()
+	for _, err := range spa.validationErrors() {
+		// the SPA's spec is the ClusterSPA's spec.template:
+		err.Field = strings.Replace(err.Field, "spec", "spec.template", 1)
+		allErrs = append(allErrs, err)
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: "autoscaling.spa.sarmadabualkaz.io", Kind: "ClusterScheduledPodAutoscaler"},
+		r.Name, allErrs)
+}
@@ -17,7 +17,10 @@ limitations under the License.
 package v1
 
 import (
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -31,49 +34,548 @@ type ScheduledPodAutoscalerSpec struct {
 	// Important: Run "make" to regenerate code after modifying this file
 
 	// Resource field for ScheduledPodAutoscaler - the resource to scale:
-	// Requires two fields - name and type:
-	Resource Resource `json:"resource"`
+	// Requires two fields - name (or selector) and type (not used in Hibernate mode):
+	// +optional
+	Resource Resource `json:"resource,omitempty"`
+
+	// mode of the SPA - options are: Scale (scale the resource to the values of scaleUp and
+	// scaleDown) or Hibernate (scale every Deployment and StatefulSet of the namespace to zero
+	// at scaleDown and restore their original replicas at scaleUp - values are not used),
+	// Note (this should default to Scale) :
+	// +kubebuilder:validation:Enum=Scale;Hibernate
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
+	// schedule template providing the times and calendar of the SPA (and the values the SPA doesn't set)
+	// - times and calendar can't be set on the SPA itself when referencing a template:
+	// +optional
+	TemplateRef *ScheduleTemplateRef `json:"templateRef,omitempty"`
 
 	// Setup for ScaleUp filed
-	// Includes two fields - time and value:
-	ScaleUp ScaleSpec `json:"scaleUp"`
+	// Includes two fields - time and value (only value with a templateRef):
+	// +optional
+	ScaleUp ScaleSpec `json:"scaleUp,omitempty"`
 
 	// Setup for ScaleDown filed
-	// Includes two fields - time and value:
-	ScaleDown ScaleSpec `json:"scaleDown"`
+	// Includes two fields - time and value (only value with a templateRef):
+	// +optional
+	ScaleDown ScaleSpec `json:"scaleDown,omitempty"`
+
+	// days, time zone and holidays of the schedule:
+	ScheduleCalendar `json:",inline"`
+
+	// what percentage values of scaleUp and scaleDown are relative to - along with clamps for the computed replicas:
+	// +optional
+	Baseline *Baseline `json:"baseline,omitempty"`
+
+	// named capacity profiles (e.g. low, normal, peak, incident) the scale steps (or a pin) can select by name:
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Profiles []CapacityProfile `json:"profiles,omitempty"`
+
+	// profile to apply instead of the one selected by the schedule - the spa.sarmadabualkaz.io/pin
+	// annotation (e.g. "incident/2h") takes precedence over it:
+	// +optional
+	Pin *ProfilePin `json:"pin,omitempty"`
+
+	// Setup for OnContention field - what to do when another writer keeps resetting the scaled field
+	// Includes two fields - action and backoffDuration:
+	// +optional
+	OnContention *ContentionPolicy `json:"onContention,omitempty"`
+
+	// leave the resources scaled by this SPA out of every ClusterScheduledPodAutoscaler selecting them
+	// - resources are matched by name (or by labels for a selector) whatever their type, as e.g. an HPA
+	// usually shares its name with the Deployment it scales:
+	// +optional
+	OverrideClusterPolicies bool `json:"overrideClusterPolicies,omitempty"`
+
+	// how long to leave the resource alone after its replicas were changed by hand
+	// (i.e. away from the value the controller last applied) - unset disables the grace period:
+	// +optional
+	ManualOverrideGracePeriod *metav1.Duration `json:"manualOverrideGracePeriod,omitempty"`
+
+	// how long the pods of a scale-up may take to become ready before the SPA is reported as Degraded
+	// - unset only tracks the time to ready:
+	// +optional
+	ReadinessDeadline *metav1.Duration `json:"readinessDeadline,omitempty"`
+
+	// how long before scaleUp.time to start scaling up, so capacity is in place at the declared time
+	// - a duration (e.g. 10m) or auto (the longest of the recently observed times to ready plus a margin):
+	// +optional
+	LeadTime string `json:"leadTime,omitempty"`
+
+	// run low-priority placeholder pods sized like the resource's pods ahead of each scale-up - so the
+	// cluster autoscaler adds nodes early - the placeholders are deleted as the scale-up starts, making way for the real pods:
+	// +optional
+	Placeholders *PlaceholderPolicy `json:"placeholders,omitempty"`
+
+	// check whether the pods of a scale-up fit before scaling up - into the namespace's ResourceQuotas
+	// and (optionally) the capacity of the nodes:
+	// +optional
+	Preflight *PreflightPolicy `json:"preflight,omitempty"`
+
+	// steer which pods a scale-down removes - by setting the pod-deletion-cost of the resource's pods before lowering its replicas
+	// (Deployments only, ReplicaSets honor the cost - StatefulSets always remove the highest ordinals):
+	// +optional
+	ScaleDownStrategy *ScaleDownStrategy `json:"scaleDownStrategy,omitempty"`
+}
+
+// PreflightPolicy configures the check of a scale-up against quotas and cluster capacity.
+type PreflightPolicy struct {
+	// compare the requests of the new pods with the allocatable capacity of the schedulable nodes
+	// left over by the pods already running too - not only with the ResourceQuota headroom:
+	// +optional
+	CheckNodeCapacity bool `json:"checkNodeCapacity,omitempty"`
+
+	// what to do with a scale-up that doesn't fit - options are: Warn (scale up anyway) or Cap (scale
+	// up only as far as fits),
+	// Note (this should default to Warn) :
+	// +kubebuilder:validation:Enum=Warn;Cap
+	// +optional
+	Action string `json:"action,omitempty"`
+}
+
+// PlaceholderPolicy configures the placeholder pods run ahead of scale-ups.
+type PlaceholderPolicy struct {
+	// how long before the scale-up starts (i.e. before scaleUp.time less the lead time) to create the placeholders,
+	// Note (this should default to 10m) :
+	// +optional
+	Ahead *metav1.Duration `json:"ahead,omitempty"`
+}
+
+// ScaleDownStrategy configures which pods a scale-down removes first.
+type ScaleDownStrategy struct {
+	// options are: NewestFirst (keep the warmest pods), UnreadyFirst (then newest first), FewestConnections
+	// (by the number in connectionsAnnotation - then newest first) or ZoneBalance (from the zones with most pods - newest first):
+	// +kubebuilder:validation:Enum=NewestFirst;UnreadyFirst;FewestConnections;ZoneBalance
+	Type string `json:"type"`
+
+	// FewestConnections only - the pod annotation holding the number of open connections of a pod (kept up to date by the pod),
+	// Note (this should default to spa.sarmadabualkaz.io/connections) :
+	// +optional
+	ConnectionsAnnotation string `json:"connectionsAnnotation,omitempty"`
 }
 
+const (
+	// ScaleDownNewestFirst removes the newest pods first.
+	ScaleDownNewestFirst = "NewestFirst"
+	// ScaleDownUnreadyFirst removes pods that aren't ready first.
+	ScaleDownUnreadyFirst = "UnreadyFirst"
+	// ScaleDownFewestConnections removes the pods with the fewest open connections first.
+	ScaleDownFewestConnections = "FewestConnections"
+	// ScaleDownZoneBalance removes pods from the zones with the most pods first.
+	ScaleDownZoneBalance = "ZoneBalance"
+
+	// DefaultConnectionsAnnotation is the pod annotation read by FewestConnections if none is set.
+	DefaultConnectionsAnnotation = "spa.sarmadabualkaz.io/connections"
+)
+
+// LeadTimeAuto makes the lead time follow the observed times to ready.
+const LeadTimeAuto = "auto"
+
+const (
+	// PreflightActionWarn reports scale-ups that don't fit but applies them anyway.
+	PreflightActionWarn = "Warn"
+	// PreflightActionCap only scales up as far as fits.
+	PreflightActionCap = "Cap"
+)
+
 type Resource struct {
-	// name of resource to manage - deployment or HPA name
-	Name string `json:"name"`
+	// name of resource to manage - deployment or HPA name,
+	// Note (either name or selector must be set) :
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// label selector of the resources to manage instead of a single named one - every resource
+	// of the given type matching it in the SPA's namespace is scaled (including ones created later):
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
 
-	// type of resource to manage - options are: deployment,
-	// HPA or annotatedDeployment (for HPA-operator managed HPAs),
+	// type of resource to manage - options are: deployment, StatefulSet, HPA,
+	// ScaledObject (KEDA) or annotatedDeployment (for HPA-operator managed HPAs),
 	// Note (this should default to deployment) :
 	// +optional
 	Type string `json:"type,omitempty"`
+
+	// apiVersion of a resource to scale through its /scale subresource (e.g. apps/v1
+	// or argoproj.io/v1alpha1) - set together with kind instead of type:
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// kind of a resource to scale through its /scale subresource (e.g. ReplicaSet
+	// or Rollout) - set together with apiVersion instead of type:
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// annotatedDeployment only - annotation the HPA-operator reads minReplicas from,
+	// Note (this should default to hpa.autoscaling.banzaicloud.io/minReplicas) :
+	// +optional
+	MinReplicasAnnotation string `json:"minReplicasAnnotation,omitempty"`
+
+	// annotatedDeployment only - annotation the HPA-operator reads maxReplicas from,
+	// Note (this should default to hpa.autoscaling.banzaicloud.io/maxReplicas) :
+	// +optional
+	MaxReplicasAnnotation string `json:"maxReplicasAnnotation,omitempty"`
+
+	// StatefulSets only - never scale below the highest ordinal whose PVC is
+	// annotated with spa.sarmadabualkaz.io/critical: "true":
+	// +optional
+	ProtectCriticalVolumes bool `json:"protectCriticalVolumes,omitempty"`
 }
 
+const (
+	// DefaultMinReplicasAnnotation is the Deployment annotation the HPA-operator reads minReplicas from.
+	DefaultMinReplicasAnnotation = "hpa.autoscaling.banzaicloud.io/minReplicas"
+	// DefaultMaxReplicasAnnotation is the Deployment annotation the HPA-operator reads maxReplicas from.
+	DefaultMaxReplicasAnnotation = "hpa.autoscaling.banzaicloud.io/maxReplicas"
+)
+
 type ScaleSpec struct {
-	// time of when scaling action to take place:
-	Time string `json:"time"`
+	// time of when scaling action to take place,
+	// Note (required unless provided by a templateRef) :
+	// +optional
+	Time string `json:"time,omitempty"`
+
+	// value to scale to (not used in Hibernate mode) - a number of replicas or a percentage of
+	// spec.baseline (e.g. 150%) - 0 scales HPAs' (and annotatedDeployments') scale target to zero
+	// instead, which pauses the HPA until the next step:
+	// +optional
+	Value *intstr.IntOrString `json:"value,omitempty"`
+
+	// name of the profile providing the value (and maxReplicas) instead - set one of value, profile or link:
+	// +optional
+	Profile string `json:"profile,omitempty"`
+
+	// workload whose replicas the value follows instead (e.g. 2x the replicas of an API Deployment)
+	// - set one of value, profile or link:
+	// +optional
+	Link *ReplicaLink `json:"link,omitempty"`
+
+	// HPAs, ScaledObjects and annotatedDeployments only - maxReplicas to set along with the value (minReplicas),
+	// Note (without it the original maxReplicas is restored at scaleDown) :
+	// +optional
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+
+	// HPAs only - metric targets and scaling behavior to apply while this step is active,
+	// Note (the HPA's original metrics and behavior are restored by a step without one) :
+	// +optional
+	HPAProfile *HPAProfile `json:"hpaProfile,omitempty"`
+}
+
+// ReplicaLink computes the value of a scale step from the current replicas of another workload:
+// ceil(status.replicas * ratio) + offset - clamped to minReplicas and maxReplicas.
+type ReplicaLink struct {
+	// kind of the workload to follow - options are: Deployment or StatefulSet,
+	// Note (this should default to Deployment) :
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// name of the workload to follow - in the SPA's namespace:
+	Name string `json:"name"`
+
+	// factor the workload's replicas are multiplied by (e.g. 2 or 0.5) - the product is rounded up,
+	// Note (this should default to 1) :
+	// +optional
+	Ratio *resource.Quantity `json:"ratio,omitempty"`
+
+	// replicas added after the ratio is applied (may be negative):
+	// +optional
+	Offset int32 `json:"offset,omitempty"`
+
+	// lower bound of the computed value:
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+
+	// upper bound of the computed value:
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+}
+
+// ScheduleCalendar holds when a schedule applies - shared by SPAs and schedule templates.
+type ScheduleCalendar struct {
+	// IANA time zone the times are in (e.g. Europe/Berlin),
+	// Note (this should default to the controller's local time zone) :
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// days the scaleUp step starts on - on other days the scaleDown step carries on,
+	// Note (this should default to every day) :
+	// +optional
+	Days []Day `json:"days,omitempty"`
+
+	// dates (YYYY-MM-DD) the scaleUp step doesn't start on, e.g. public holidays:
+	// +optional
+	Holidays []string `json:"holidays,omitempty"`
+}
+
+// Baseline is what percentage values are relative to.
+// Percentages are rounded up - so any percentage above 0% of a non-zero baseline is at least 1 replica -
+// and then clamped to minReplicas and maxReplicas.
+type Baseline struct {
+	// source of the baseline replicas - options are: Original (the replicas of the resource when the
+	// SPA first saw it) or Yesterday (the replicas of the resource when the active step started
+	// yesterday as chosen by an HPA - HPAs and annotatedDeployments only, falling back to Original
+	// when there is no record of it or for resources whose replicas the SPA sets itself),
+	// Note (this should default to Original) :
+	// +kubebuilder:validation:Enum=Original;Yesterday
+	// +optional
+	Source string `json:"source,omitempty"`
+
+	// lower bound of replicas computed from a percentage:
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+
+	// upper bound of replicas computed from a percentage:
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+}
+
+const (
+	// BaselineOriginal makes percentages relative to the replicas of the resource when the SPA first saw it.
+	BaselineOriginal = "Original"
+	// BaselineYesterday makes percentages relative to the replicas of the resource when the active step started yesterday.
+	BaselineYesterday = "Yesterday"
+)
+
+// CapacityProfile is a named capacity the scale steps (or a pin) can select.
+type CapacityProfile struct {
+	// name of the profile, e.g. peak:
+	Name string `json:"name"`
 
 	// value to scale to:
-	Value *int32 `json:"value"`
+	Value int32 `json:"value"`
+
+	// HPAs, ScaledObjects and annotatedDeployments only - maxReplicas to set along with the value (minReplicas):
+	// +optional
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+}
+
+// ProfilePin applies a profile regardless of the schedule.
+type ProfilePin struct {
+	// name of the profile to apply:
+	Profile string `json:"profile"`
+
+	// how long to apply it for - counted from when the controller first sees the pin,
+	// Note (unset pins the profile until the pin is removed) :
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+const (
+	// PinAnnotation pins a profile from the command line - "<profile>" or "<profile>/<duration>", e.g. "incident/2h".
+	PinAnnotation = "spa.sarmadabualkaz.io/pin"
+)
+
+// HolidayLayout is the layout of the dates in holidays.
+const HolidayLayout = "2006-01-02"
+
+// Day is a day of the week.
+// +kubebuilder:validation:Enum=Monday;Tuesday;Wednesday;Thursday;Friday;Saturday;Sunday
+type Day string
+
+// ScheduleTemplateRef references the ScheduleTemplate (or ClusterScheduleTemplate) of an SPA.
+type ScheduleTemplateRef struct {
+	// kind of the template - options are: ScheduleTemplate (in the SPA's namespace) or ClusterScheduleTemplate,
+	// Note (this should default to ScheduleTemplate) :
+	// +kubebuilder:validation:Enum=ScheduleTemplate;ClusterScheduleTemplate
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// name of the template:
+	Name string `json:"name"`
+}
+
+// HPAProfile holds the autoscaling/v2 fields of an HPA that can be changed on a schedule.
+type HPAProfile struct {
+	// metric targets replacing the HPA's spec.metrics:
+	// +optional
+	Metrics []autoscalingv2beta2.MetricSpec `json:"metrics,omitempty"`
+
+	// scaling behavior replacing the HPA's spec.behavior:
+	// +optional
+	Behavior *autoscalingv2beta2.HorizontalPodAutoscalerBehavior `json:"behavior,omitempty"`
+}
+
+type ContentionPolicy struct {
+	// action to take while the scaled field is contested - options are: Enforce
+	// (keep applying the schedule), Backoff (re-apply with a doubling delay) or
+	// Yield (leave the resource to the other writer),
+	// Note (this should default to Enforce) :
+	// +kubebuilder:validation:Enum=Enforce;Backoff;Yield
+	// +optional
+	Action string `json:"action,omitempty"`
+
+	// delay before the first re-apply with the Backoff action - doubled every time the field is contested again:
+	// +optional
+	BackoffDuration *metav1.Duration `json:"backoffDuration,omitempty"`
 }
 
+const (
+	// ContentionActionEnforce keeps re-applying the schedule on every reconcile.
+	ContentionActionEnforce = "Enforce"
+	// ContentionActionBackoff re-applies the schedule with an exponentially growing delay.
+	ContentionActionBackoff = "Backoff"
+	// ContentionActionYield stops writing to the resource while it is contested.
+	ContentionActionYield = "Yield"
+)
+
+const (
+	// ModeScale scales the resource to the values of scaleUp and scaleDown.
+	ModeScale = "Scale"
+	// ModeHibernate scales every Deployment and StatefulSet of the namespace to zero between scaleDown and scaleUp.
+	ModeHibernate = "Hibernate"
+)
+
+const (
+	// ConditionContested is True while another field manager is writing the field the SPA scales - or, for a
+	// ScheduledScalingGroup, while members are scaled by an SPA or another group too.
+	ConditionContested = "Contested"
+	// ConditionHibernating is True while a Hibernate mode SPA keeps the namespace scaled to zero.
+	ConditionHibernating = "Hibernating"
+	// ConditionDegraded is True while the pods of a scale-up aren't ready within spec.readinessDeadline.
+	ConditionDegraded = "Degraded"
+	// ConditionInsufficientCapacity is True while the pods of the scheduled scale-up don't fit the quotas (or nodes).
+	ConditionInsufficientCapacity = "InsufficientCapacity"
+)
+
 // ScheduledPodAutoscalerStatus defines the observed state of ScheduledPodAutoscaler
 type ScheduledPodAutoscalerStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
+	// State of the resource named by spec.resource.name - with a selector only the
+	// conditions summarize the state of every selected resource:
+	TargetStatus `json:",inline"`
+
+	// Selector only - state of every resource currently matching spec.resource.selector.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Targets []NamedTargetStatus `json:"targets,omitempty"`
+
+	// Name of the profile currently applied - blank while the schedule's steps set values directly.
+	// +optional
+	ActiveProfile string `json:"activeProfile,omitempty"`
+
+	// Why the active profile (or value) is applied, e.g. selected by the scaleUp step or pinned by annotation.
+	// +optional
+	ActiveProfileReason string `json:"activeProfileReason,omitempty"`
+
+	// The pin last seen, as <source>:<profile>/<duration> - used to notice a new pin.
+	// +optional
+	ObservedPin string `json:"observedPin,omitempty"`
+
+	// Information when the observed pin expires - unset for pins without duration.
+	// +optional
+	PinExpirationTime *metav1.Time `json:"pinExpirationTime,omitempty"`
+
+	// How long before scaleUp.time the scale-up starts - as worked out from spec.leadTime.
+	// +optional
+	EffectiveLeadTime *metav1.Duration `json:"effectiveLeadTime,omitempty"`
+}
+
+// NamedTargetStatus is the observed state of one of the resources selected by spec.resource.selector.
+type NamedTargetStatus struct {
+	// name of the selected resource:
+	Name string `json:"name"`
+
+	TargetStatus `json:",inline"`
+}
+
+// TargetStatus is the observed state of a single resource scaled by the SPA.
+type TargetStatus struct {
 	// Information when was the last time a scaling action was successfully scheduled.
 	// +optional
 	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// Replica count the controller last applied (or found already in place) on the resource.
+	// +optional
+	LastAppliedReplicas *int32 `json:"lastAppliedReplicas,omitempty"`
+
+	// Information when a manual change of the resource's replicas was first noticed - unset when there is none.
+	// +optional
+	ManualOverrideTime *metav1.Time `json:"manualOverrideTime,omitempty"`
+
+	// HPAs, ScaledObjects and annotatedDeployments only - maxReplicas the target had before the controller first changed it,
+	// restored when the scale-down window begins.
+	// +optional
+	OriginalMaxReplicas *int32 `json:"originalMaxReplicas,omitempty"`
+
+	// Replicas of the resource when the SPA first saw it - the Original baseline of percentage values.
+	// +optional
+	OriginalReplicas *int32 `json:"originalReplicas,omitempty"`
+
+	// Replicas of the resource as recorded at the start of each step over the last day (HPAs: the replicas
+	// the HPA chose) - the Yesterday baseline of percentage values. Only kept for that baseline, of HPAs and annotatedDeployments.
+	// +optional
+	ReplicaHistory []ReplicaSample `json:"replicaHistory,omitempty"`
+
+	// HPAs and annotatedDeployments only - replicas of the resource scaled by the HPA before it was
+	// scaled to zero for a scale step with value 0, restored when the step is over.
+	// +optional
+	OriginalTargetReplicas *int32 `json:"originalTargetReplicas,omitempty"`
+
+	// HPAs only - name of the scale step (scaleUp or scaleDown) whose hpaProfile is applied
+	// to the HPA - blank while the HPA has its original metrics and behavior.
+	// +optional
+	ActiveHPAProfile string `json:"activeHPAProfile,omitempty"`
+
+	// HPAs only - metrics and behavior the HPA had before the first hpaProfile was applied.
+	// +optional
+	OriginalHPAProfile *HPAProfile `json:"originalHPAProfile,omitempty"`
+
+	// Information when the controller last scaled the resource up - unset once the scheduled replicas are ready.
+	// +optional
+	ScaleUpTime *metav1.Time `json:"scaleUpTime,omitempty"`
+
+	// Ready replicas the last scale-up waits for - unset once they are ready.
+	// +optional
+	ExpectedReadyReplicas *int32 `json:"expectedReadyReplicas,omitempty"`
+
+	// Number of placeholder pods run for the resource ahead of the next scale-up.
+	// +optional
+	PlaceholderReplicas int32 `json:"placeholderReplicas,omitempty"`
+
+	// How long the latest scale-ups took until their replicas were ready - most recent last.
+	// +optional
+	TimesToReady []metav1.Duration `json:"timesToReady,omitempty"`
+
+	// Last time the pod-deletion-cost of the pods was set ahead of a scale-down - cleared once the
+	// annotations are removed again.
+	// +optional
+	DeletionCostTime *metav1.Time `json:"deletionCostTime,omitempty"`
+
+	// Number of times the scaled field was found overwritten by another field manager
+	// since the resource was last uncontested.
+	// +optional
+	ContestedCount int32 `json:"contestedCount,omitempty"`
+
+	// Information when the scaled field was last found overwritten by another field manager.
+	// +optional
+	LastContestedTime *metav1.Time `json:"lastContestedTime,omitempty"`
+
+	// Latest available observations of the SPA's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// ReplicaSample is the replicas of a resource at a point in time.
+type ReplicaSample struct {
+	Time     metav1.Time `json:"time"`
+	Replicas int32       `json:"replicas"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:shortName=spa
+// +kubebuilder:subresource:status
 
 // ScheduledPodAutoscaler is the Schema for the scheduledpodautoscalers API
 type ScheduledPodAutoscaler struct {
@@ -18,12 +18,16 @@ package v1
 
 import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"strconv"
+	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
@@ -47,10 +51,62 @@ var _ webhook.Defaulter = &ScheduledPodAutoscaler{}
 func (r *ScheduledPodAutoscaler) Default() {
 	scheduledpodautoscalerlog.Info("default", "name", r.Name)
 
-	// default 'Spec.Resource.Type' to 'deployment' if set blank
-	if r.Spec.Resource.Type == "" {
+	// default 'Spec.Mode' to 'Scale' if set blank
+	if r.Spec.Mode == "" {
+		r.Spec.Mode = ModeScale
+	}
+
+	// default 'Spec.Resource.Type' to 'deployment' if set blank (and the resource isn't referenced by kind)
+	if r.Spec.Mode != ModeHibernate && r.Spec.Resource.Type == "" && r.Spec.Resource.Kind == "" {
 		r.Spec.Resource.Type = "deployment"
 	}
+
+	// default the HPA-operator annotations of annotatedDeployments if set blank
+	if r.Spec.Resource.Type == "annotatedDeployment" || r.Spec.Resource.Type == "AnnotatedDeployment" {
+		if r.Spec.Resource.MinReplicasAnnotation == "" {
+			r.Spec.Resource.MinReplicasAnnotation = DefaultMinReplicasAnnotation
+		}
+		if r.Spec.Resource.MaxReplicasAnnotation == "" {
+			r.Spec.Resource.MaxReplicasAnnotation = DefaultMaxReplicasAnnotation
+		}
+	}
+
+	// default 'Spec.TemplateRef.Kind' to 'ScheduleTemplate' if set blank
+	if r.Spec.TemplateRef != nil && r.Spec.TemplateRef.Kind == "" {
+		r.Spec.TemplateRef.Kind = ScheduleTemplateKind
+	}
+
+	// default the kind of linked workloads to 'Deployment' if set blank
+	for _, step := range []*ScaleSpec{&r.Spec.ScaleUp, &r.Spec.ScaleDown} {
+		if step.Link != nil && step.Link.Kind == "" {
+			step.Link.Kind = "Deployment"
+		}
+	}
+
+	// default 'Spec.Baseline.Source' to 'Original' if set blank
+	if r.Spec.Baseline != nil && r.Spec.Baseline.Source == "" {
+		r.Spec.Baseline.Source = BaselineOriginal
+	}
+
+	// default 'Spec.Preflight.Action' to 'Warn' if set blank
+	if r.Spec.Preflight != nil && r.Spec.Preflight.Action == "" {
+		r.Spec.Preflight.Action = PreflightActionWarn
+	}
+
+	// default 'Spec.Placeholders.Ahead' to '10m' if not set
+	if r.Spec.Placeholders != nil && r.Spec.Placeholders.Ahead == nil {
+		r.Spec.Placeholders.Ahead = &metav1.Duration{Duration: 10 * time.Minute}
+	}
+
+	// default 'Spec.ScaleDownStrategy.ConnectionsAnnotation' for 'FewestConnections' if set blank
+	if r.Spec.ScaleDownStrategy != nil && r.Spec.ScaleDownStrategy.Type == ScaleDownFewestConnections && r.Spec.ScaleDownStrategy.ConnectionsAnnotation == "" {
+		r.Spec.ScaleDownStrategy.ConnectionsAnnotation = DefaultConnectionsAnnotation
+	}
+
+	// default 'Spec.OnContention.Action' to 'Enforce' if set blank
+	if r.Spec.OnContention != nil && r.Spec.OnContention.Action == "" {
+		r.Spec.OnContention.Action = ContentionActionEnforce
+	}
 }
 
 // TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
@@ -83,6 +139,18 @@ func (r *ScheduledPodAutoscaler) ValidateDelete() error {
 }
 
 func (r *ScheduledPodAutoscaler) validateScheduledPodAutoscaler() error {
+	allErrs := r.validationErrors()
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: "autoscaling.spa.sarmadabualkaz.io", Kind: "ScheduledPodAutoscaler"},
+		r.Name, allErrs)
+}
+
+// validationErrors collects the errors of the spec - of an SPA or the template of a ClusterSPA.
+func (r *ScheduledPodAutoscaler) validationErrors() field.ErrorList {
 	var allErrs field.ErrorList
 	if err := r.validateScheduledPodAutoscalerSpec(); err != nil {
 		allErrs = append(allErrs, err)
@@ -91,26 +159,140 @@ func (r *ScheduledPodAutoscaler) validateScheduledPodAutoscaler() error {
 	if err := r.validateScheduledPodAutoscalerTimeEnteries(); err != nil {
 		allErrs = append(allErrs, err)
 	}
+	return allErrs
+}
 
-	if len(allErrs) == 0 {
+func (r *ScheduledPodAutoscaler) validateScheduledPodAutoscalerSpec() *field.Error {
+	// The field helpers from Kubernetes API machinery to return
+	// structured validation errors
+
+	if r.Spec.Mode == ModeHibernate {
+		// the whole namespace is scaled to zero and back - resource and values are not used:
 		return nil
+	} else if r.Spec.Resource.Name == "" && r.Spec.Resource.Selector == nil {
+		return field.Invalid(field.NewPath("spec").Child("resource").Key("name"), r.Spec.Resource.Name, "name cannot be blank (unless resource.selector is set) and must be no more than 52 characters")
+	} else if r.Spec.Resource.Name != "" && r.Spec.Resource.Selector != nil {
+		return field.Invalid(field.NewPath("spec").Child("resource").Key("selector"), r.Spec.Resource.Selector, "resource.selector cannot be set together with resource.name")
+	} else if r.Spec.Resource.Selector != nil && r.Spec.Resource.Kind != "" {
+		return field.Invalid(field.NewPath("spec").Child("resource").Key("selector"), r.Spec.Resource.Selector, "resource.selector can only select resources by resource.type - not by resource.kind")
+	} else if _, err := metav1.LabelSelectorAsSelector(r.Spec.Resource.Selector); err != nil {
+		return field.Invalid(field.NewPath("spec").Child("resource").Key("selector"), r.Spec.Resource.Selector, err.Error())
+	} else if r.Spec.Resource.Kind != "" && r.Spec.Resource.Type != "" {
+		return field.Invalid(field.NewPath("spec").Child("resource").Key("kind"), r.Spec.Resource.Kind, "resource.kind cannot be set together with resource.type")
+	} else if r.Spec.Resource.Kind != "" && r.Spec.Resource.APIVersion == "" {
+		return field.Invalid(field.NewPath("spec").Child("resource").Key("apiVersion"), r.Spec.Resource.APIVersion, "resource.apiVersion is required when resource.kind is set")
+	} else if r.Spec.TemplateRef == nil && r.Spec.ScaleUp.Value == nil && r.Spec.ScaleUp.Profile == "" && r.Spec.ScaleUp.Link == nil {
+		return field.Required(field.NewPath("spec").Child("scaleUp").Key("value"), "scaleUp.value (or scaleUp.profile or scaleUp.link) is required unless mode is Hibernate (or it is provided by a templateRef)")
+	} else if r.Spec.TemplateRef == nil && r.Spec.ScaleDown.Value == nil && r.Spec.ScaleDown.Profile == "" && r.Spec.ScaleDown.Link == nil {
+		return field.Required(field.NewPath("spec").Child("scaleDown").Key("value"), "scaleDown.value (or scaleDown.profile or scaleDown.link) is required unless mode is Hibernate (or it is provided by a templateRef)")
+	} else if err := r.validateCapacityProfiles(); err != nil {
+		return err
+	} else if r.Spec.Baseline != nil && r.Spec.Baseline.MinReplicas != nil && r.Spec.Baseline.MaxReplicas != nil && *r.Spec.Baseline.MaxReplicas < *r.Spec.Baseline.MinReplicas {
+		return field.Invalid(field.NewPath("spec").Child("baseline").Key("maxReplicas"), r.Spec.Baseline.MaxReplicas, "baseline.maxReplicas is invalid - needs to be at least equal to baseline.minReplicas")
 	}
+	return validateScaleSteps(r.Spec.ScaleUp, r.Spec.ScaleDown)
+}
 
-	return apierrors.NewInvalid(
-		schema.GroupKind{Group: "autoscaling.spa.sarmadabualkaz.io", Kind: "ScheduledPodAutoscaler"},
-		r.Name, allErrs)
+// validateScaleSteps checks the values, links and maxReplicas of both scale steps - of an SPA or a schedule template.
+func validateScaleSteps(scaleUp ScaleSpec, scaleDown ScaleSpec) *field.Error {
+	if err := validateReplicaLink("scaleUp", scaleUp); err != nil {
+		return err
+	} else if err := validateReplicaLink("scaleDown", scaleDown); err != nil {
+		return err
+	} else if err := validateScaleValue("scaleUp", scaleUp.Value); err != nil {
+		return err
+	} else if err := validateScaleValue("scaleDown", scaleDown.Value); err != nil {
+		return err
+	} else if scaleUp.Value != nil && scaleDown.Value != nil && scaleUp.Value.Type == scaleDown.Value.Type && scaleValueAmount(scaleUp.Value) <= scaleValueAmount(scaleDown.Value) {
+		return field.Invalid(field.NewPath("spec").Child("scaleUp").Key("value"), scaleUp.Value, "scalueUp.value is invalid - needs to be more than scaleDown.value")
+	} else if scaleUp.MaxReplicas != nil && scaleUp.Value != nil && scaleUp.Value.Type == intstr.Int && *scaleUp.MaxReplicas < scaleUp.Value.IntVal {
+		return field.Invalid(field.NewPath("spec").Child("scaleUp").Key("maxReplicas"), scaleUp.MaxReplicas, "scaleUp.maxReplicas is invalid - needs to be at least equal to scaleUp.value")
+	} else if scaleDown.MaxReplicas != nil && scaleDown.Value != nil && scaleDown.Value.Type == intstr.Int && *scaleDown.MaxReplicas < scaleDown.Value.IntVal {
+		return field.Invalid(field.NewPath("spec").Child("scaleDown").Key("maxReplicas"), scaleDown.MaxReplicas, "scaleDown.maxReplicas is invalid - needs to be at least equal to scaleDown.value")
+	}
+	return nil
 }
 
-func (r *ScheduledPodAutoscaler) validateScheduledPodAutoscalerSpec() *field.Error {
-	// The field helpers from Kubernetes API machinery to return
-	// structured validation errors
+// validateScaleValue checks that the value of a scale step is a number or a percentage (e.g. 150%) - and not negative.
+func validateScaleValue(step string, value *intstr.IntOrString) *field.Error {
+	if value == nil {
+		return nil
+	}
+
+	if value.Type == intstr.String {
+		if !strings.HasSuffix(value.StrVal, "%") {
+			return field.Invalid(field.NewPath("spec").Child(step).Key("value"), value.StrVal, step+".value is invalid - needs to be a number or a percentage (e.g. 150%)")
+		} else if _, err := strconv.Atoi(strings.TrimSuffix(value.StrVal, "%")); err != nil {
+			return field.Invalid(field.NewPath("spec").Child(step).Key("value"), value.StrVal, step+".value is invalid - needs to be a number or a percentage (e.g. 150%)")
+		}
+	}
+
+	if scaleValueAmount(value) < 0 {
+		return field.Invalid(field.NewPath("spec").Child(step).Key("value"), value.String(), step+".value is invalid - needs to be at least equal to 0")
+	}
+	return nil
+}
 
-	if r.Spec.Resource.Name == "" {
-		return field.Invalid(field.NewPath("spec").Child("resource").Key("name"), r.Spec.Resource.Name, "name cannot be blank and must be no more than 52 characters")
-	} else if *r.Spec.ScaleDown.Value <= 0 {
-		return field.Invalid(field.NewPath("spec").Child("scaleDown").Key("value"), r.Spec.ScaleDown.Value, "scalueDown.value is invalid - needs to be at least equal to 1")
-	} else if *r.Spec.ScaleUp.Value <= *r.Spec.ScaleDown.Value {
-		return field.Invalid(field.NewPath("spec").Child("scaleUp").Key("value"), r.Spec.ScaleUp.Value, "scalueUp.value is invalid - needs to be more than scaleDown.value")
+// validateReplicaLink checks that a linked scale step sets no value or profile - and that the link is usable.
+func validateReplicaLink(step string, spec ScaleSpec) *field.Error {
+	link := spec.Link
+	if link == nil {
+		return nil
+	}
+
+	path := field.NewPath("spec").Child(step).Child("link")
+	if spec.Value != nil || spec.Profile != "" {
+		return field.Invalid(path, link.Name, step+".link cannot be set together with "+step+".value or "+step+".profile")
+	} else if link.Name == "" {
+		return field.Required(path.Key("name"), step+".link.name is required")
+	} else if link.Ratio != nil && link.Ratio.Sign() <= 0 {
+		return field.Invalid(path.Key("ratio"), link.Ratio.String(), step+".link.ratio is invalid - needs to be more than 0")
+	} else if link.MinReplicas != nil && link.MaxReplicas != nil && *link.MaxReplicas < *link.MinReplicas {
+		return field.Invalid(path.Key("maxReplicas"), link.MaxReplicas, step+".link.maxReplicas is invalid - needs to be at least equal to "+step+".link.minReplicas")
+	}
+	return nil
+}
+
+// scaleValueAmount returns the number of a value - replicas, or percent for percentages.
+func scaleValueAmount(value *intstr.IntOrString) int {
+	if value.Type == intstr.Int {
+		return value.IntValue()
+	}
+
+	amount, _ := strconv.Atoi(strings.TrimSuffix(value.StrVal, "%"))
+	return amount
+}
+
+func (r *ScheduledPodAutoscaler) validateCapacityProfiles() *field.Error {
+	profiles := make(map[string]bool, len(r.Spec.Profiles))
+	for i, profile := range r.Spec.Profiles {
+		path := field.NewPath("spec").Child("profiles").Index(i)
+		if profile.Value < 0 {
+			return field.Invalid(path.Child("value"), profile.Value, "profile value is invalid - needs to be at least equal to 0")
+		} else if profile.MaxReplicas != nil && *profile.MaxReplicas < profile.Value {
+			return field.Invalid(path.Child("maxReplicas"), profile.MaxReplicas, "profile maxReplicas is invalid - needs to be at least equal to the profile value")
+		}
+		profiles[profile.Name] = true
+	}
+
+	for _, name := range []string{"scaleUp", "scaleDown"} {
+		step := r.Spec.ScaleUp
+		if name == "scaleDown" {
+			step = r.Spec.ScaleDown
+		}
+
+		if step.Profile == "" {
+			continue
+		}
+		if step.Value != nil {
+			return field.Invalid(field.NewPath("spec").Child(name).Key("profile"), step.Profile, name+".profile cannot be set together with "+name+".value")
+		} else if !profiles[step.Profile] {
+			return field.NotFound(field.NewPath("spec").Child(name).Key("profile"), step.Profile)
+		}
+	}
+
+	if r.Spec.Pin != nil && !profiles[r.Spec.Pin.Profile] {
+		return field.NotFound(field.NewPath("spec").Child("pin").Key("profile"), r.Spec.Pin.Profile)
 	}
 	return nil
 }
@@ -119,12 +301,51 @@ func (r *ScheduledPodAutoscaler) validateScheduledPodAutoscalerTimeEnteries() *f
 	// The field helpers from Kubernetes API machinery to return
 	// structured validation errors
 
+	if r.Spec.LeadTime != "" && r.Spec.LeadTime != LeadTimeAuto {
+		if leadTime, err := time.ParseDuration(r.Spec.LeadTime); err != nil || leadTime < 0 {
+			return field.Invalid(field.NewPath("spec").Child("leadTime"), r.Spec.LeadTime, "leadTime is invalid - needs to be auto or a duration of at least 0 (e.g. 10m)")
+		}
+	}
+
+	if r.Spec.Placeholders != nil && r.Spec.Placeholders.Ahead != nil && r.Spec.Placeholders.Ahead.Duration <= 0 {
+		return field.Invalid(field.NewPath("spec").Child("placeholders").Child("ahead"), r.Spec.Placeholders.Ahead.Duration.String(), "placeholders.ahead is invalid - needs to be a duration above 0 (e.g. 10m)")
+	}
+
+	// times and calendar come from the template when referencing one:
+	if r.Spec.TemplateRef != nil {
+		if r.Spec.ScaleUp.Time != "" || r.Spec.ScaleDown.Time != "" {
+			return field.Invalid(field.NewPath("spec").Child("templateRef"), r.Spec.TemplateRef.Name, "scaleUp.time and scaleDown.time cannot be set together with templateRef - only values can be overridden")
+		}
+		if r.Spec.TimeZone != "" || len(r.Spec.Days) > 0 || len(r.Spec.Holidays) > 0 {
+			return field.Invalid(field.NewPath("spec").Child("templateRef"), r.Spec.TemplateRef.Name, "timeZone, days and holidays cannot be set together with templateRef - only values can be overridden")
+		}
+		return nil
+	}
+
+	return validateSchedule(r.Spec.ScaleUp, r.Spec.ScaleDown, r.Spec.ScheduleCalendar)
+}
+
+// validateSchedule checks the times and calendar of a schedule - of an SPA or a schedule template.
+func validateSchedule(scaleUp ScaleSpec, scaleDown ScaleSpec, calendar ScheduleCalendar) *field.Error {
 	//check if time is validly entred
-	if _, err := time.Parse(time.Kitchen, r.Spec.ScaleUp.Time); err != nil {
-		return field.Invalid(field.NewPath("spec").Child("scaleUp").Key("time"), r.Spec.ScaleUp.Time, err.Error())
+	if _, err := time.Parse(time.Kitchen, scaleUp.Time); err != nil {
+		return field.Invalid(field.NewPath("spec").Child("scaleUp").Key("time"), scaleUp.Time, err.Error())
+	}
+	if _, err := time.Parse(time.Kitchen, scaleDown.Time); err != nil {
+		return field.Invalid(field.NewPath("spec").Child("scaleDown").Key("time"), scaleDown.Time, err.Error())
+	}
+	if scaleUp.Time == scaleDown.Time {
+		return field.Invalid(field.NewPath("spec").Child("scaleDown").Key("time"), scaleDown.Time, "scaleDown.time is invalid - needs to differ from scaleUp.time")
+	}
+
+	//check if time zone and holidays are validly entred
+	if _, err := time.LoadLocation(calendar.TimeZone); err != nil {
+		return field.Invalid(field.NewPath("spec").Child("timeZone"), calendar.TimeZone, err.Error())
 	}
-	if _, err := time.Parse(time.Kitchen, r.Spec.ScaleDown.Time); err != nil {
-		return field.Invalid(field.NewPath("spec").Child("scaleDown").Key("time"), r.Spec.ScaleDown.Time, err.Error())
+	for i, holiday := range calendar.Holidays {
+		if _, err := time.Parse(HolidayLayout, holiday); err != nil {
+			return field.Invalid(field.NewPath("spec").Child("holidays").Index(i), holiday, err.Error())
+		}
 	}
 	return nil
 }
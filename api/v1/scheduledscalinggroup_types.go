@@ -0,0 +1,161 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ScheduledScalingGroupSpec defines workloads scaled together on one schedule - in the order of their dependencies
+type ScheduledScalingGroupSpec struct {
+	// Setup for ScaleUp filed
+	// Includes one field - time (values are set per member):
+	ScaleUp GroupStep `json:"scaleUp"`
+
+	// Setup for ScaleDown filed
+	// Includes one field - time (values are set per member):
+	ScaleDown GroupStep `json:"scaleDown"`
+
+	// days, time zone and holidays of the schedule:
+	ScheduleCalendar `json:",inline"`
+
+	// workloads of the group - at scaleUp a member is only scaled once the members it depends on are ready,
+	// at scaleDown only once the members depending on it are:
+	// +listType=map
+	// +listMapKey=name
+	Members []GroupMember `json:"members"`
+
+	// how long to wait for a stage to become ready before reporting it as timed out - later stages
+	// aren't scaled until it is ready,
+	// Note (this should default to 10m) :
+	// +optional
+	StageTimeout *metav1.Duration `json:"stageTimeout,omitempty"`
+}
+
+// GroupStep is a scale step of a ScheduledScalingGroup.
+type GroupStep struct {
+	// time of when scaling action to take place:
+	Time string `json:"time"`
+}
+
+// GroupMember is a workload of a ScheduledScalingGroup.
+type GroupMember struct {
+	// name of the member - referenced by dependsOn:
+	Name string `json:"name"`
+
+	// kind of the workload - options are: Deployment or StatefulSet,
+	// Note (this should default to Deployment) :
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// name of the workload - in the group's namespace,
+	// Note (this should default to the member's name) :
+	// +optional
+	ResourceName string `json:"resourceName,omitempty"`
+
+	// value to scale to at scaleUp:
+	// +kubebuilder:validation:Minimum=0
+	ScaleUpValue int32 `json:"scaleUpValue"`
+
+	// value to scale to at scaleDown:
+	// +kubebuilder:validation:Minimum=0
+	ScaleDownValue int32 `json:"scaleDownValue"`
+
+	// names of the members that must be ready before this one is scaled up (and that are only
+	// scaled down once this one is):
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+const (
+	// StagePending is the phase of a stage not scaled yet.
+	StagePending = "Pending"
+	// StageScaling is the phase of a stage scaled and waited for.
+	StageScaling = "Scaling"
+	// StageReady is the phase of a stage whose members all run the replicas of the step.
+	StageReady = "Ready"
+	// StageTimedOut is the phase of a stage that didn't become ready within spec.stageTimeout.
+	StageTimedOut = "TimedOut"
+
+	// ConditionReady is true when every stage of the active step of a ScheduledScalingGroup is ready.
+	ConditionReady = "Ready"
+)
+
+// ScheduledScalingGroupStatus defines the observed state of ScheduledScalingGroup
+type ScheduledScalingGroupStatus struct {
+	// scale step being rolled out - scaleUp or scaleDown:
+	// +optional
+	ActiveStep string `json:"activeStep,omitempty"`
+
+	// Information when the active step started - a new step starts the rollout over.
+	// +optional
+	StepStartTime *metav1.Time `json:"stepStartTime,omitempty"`
+
+	// Progress of the stages of the active step - in the order they are scaled.
+	// +optional
+	Stages []GroupStageStatus `json:"stages,omitempty"`
+
+	// Latest available observations of the group's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// GroupStageStatus is the progress of a stage - the members whose dependencies are all in earlier stages.
+type GroupStageStatus struct {
+	// names of the members of the stage:
+	Members []string `json:"members"`
+
+	// phase of the stage - one of Pending, Scaling, Ready or TimedOut:
+	Phase string `json:"phase"`
+
+	// Information when the members of the stage were scaled.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Members of the stage not ready yet - with why.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=ssg
+// +kubebuilder:subresource:status
+
+// ScheduledScalingGroup is the Schema for the scheduledscalinggroups API
+type ScheduledScalingGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ScheduledScalingGroupSpec   `json:"spec,omitempty"`
+	Status ScheduledScalingGroupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ScheduledScalingGroupList contains a list of ScheduledScalingGroup
+type ScheduledScalingGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ScheduledScalingGroup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ScheduledScalingGroup{}, &ScheduledScalingGroupList{})
+}
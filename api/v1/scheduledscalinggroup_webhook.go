@@ -0,0 +1,143 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var scheduledscalinggrouplog = logf.Log.WithName("scheduledscalinggroup-resource")
+
+func (r *ScheduledScalingGroup) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-autoscaling-spa-sarmadabualkaz-io-v1-scheduledscalinggroup,mutating=false,failurePolicy=fail,groups=autoscaling.spa.sarmadabualkaz.io,resources=scheduledscalinggroups,versions=v1,name=vscheduledscalinggroup.kb.io
+
+var _ webhook.Validator = &ScheduledScalingGroup{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ScheduledScalingGroup) ValidateCreate() error {
+	scheduledscalinggrouplog.Info("validate create", "name", r.Name)
+	return r.validateScheduledScalingGroup()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ScheduledScalingGroup) ValidateUpdate(old runtime.Object) error {
+	scheduledscalinggrouplog.Info("validate update", "name", r.Name)
+	return r.validateScheduledScalingGroup()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ScheduledScalingGroup) ValidateDelete() error {
+	return nil
+}
+
+// validateScheduledScalingGroup checks the times and calendar of the group like those of an SPA, and that the members
+// can be put in stages - otherwise the group is only found broken when it is reconciled.
+func (r *ScheduledScalingGroup) validateScheduledScalingGroup() error {
+	var allErrs field.ErrorList
+	if err := validateSchedule(ScaleSpec{Time: r.Spec.ScaleUp.Time}, ScaleSpec{Time: r.Spec.ScaleDown.Time}, r.Spec.ScheduleCalendar); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	if r.Spec.StageTimeout != nil && r.Spec.StageTimeout.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("stageTimeout"), r.Spec.StageTimeout.Duration.String(), "stageTimeout is invalid - needs to be a duration above 0 (e.g. 10m)"))
+	}
+	if err := validateGroupMembers(r.Spec.Members); err != nil {
+		allErrs = append(allErrs, err)
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: "autoscaling.spa.sarmadabualkaz.io", Kind: "ScheduledScalingGroup"},
+		r.Name, allErrs)
+}
+
+// validateGroupMembers checks that every workload is a member only once, and that dependsOn only names other members
+// without a cycle.
+func validateGroupMembers(members []GroupMember) *field.Error {
+	path := field.NewPath("spec").Child("members")
+
+	byName := make(map[string]GroupMember, len(members))
+	workloads := make(map[string]bool, len(members))
+	for i, member := range members {
+		kind, name := member.Kind, member.ResourceName
+		if kind == "" {
+			kind = "Deployment"
+		}
+		if name == "" {
+			name = member.Name
+		}
+		if workloads[kind+"/"+name] {
+			return field.Duplicate(path.Index(i), kind+"/"+name)
+		}
+		workloads[kind+"/"+name] = true
+		byName[member.Name] = member
+	}
+
+	for i, member := range members {
+		for j, dependency := range member.DependsOn {
+			if _, ok := byName[dependency]; !ok || dependency == member.Name {
+				return field.Invalid(path.Index(i).Child("dependsOn").Index(j), dependency, "dependsOn is invalid - needs to name another member of the group")
+			}
+		}
+	}
+
+	// visiting marks the members on the current path of the walk - reaching one of them again closes a cycle:
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(members))
+	var walk func(name string) bool
+	walk = func(name string) bool {
+		switch state[name] {
+		case visiting:
+			return false
+		case visited:
+			return true
+		}
+
+		state[name] = visiting
+		for _, dependency := range byName[name].DependsOn {
+			if !walk(dependency) {
+				return false
+			}
+		}
+		state[name] = visited
+		return true
+	}
+
+	for i, member := range members {
+		if !walk(member.Name) {
+			return field.Invalid(path.Index(i).Child("dependsOn"), member.DependsOn, "dependsOn is invalid - the dependencies of the members have a cycle")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ScheduleTemplateSpec defines a named schedule shared by the SPAs referencing it
+type ScheduleTemplateSpec struct {
+	// Setup for ScaleUp filed
+	// Includes two fields - time and value (value can be overridden by the SPAs):
+	ScaleUp ScaleSpec `json:"scaleUp"`
+
+	// Setup for ScaleDown filed
+	// Includes two fields - time and value (value can be overridden by the SPAs):
+	ScaleDown ScaleSpec `json:"scaleDown"`
+
+	// days, time zone and holidays of the schedule:
+	ScheduleCalendar `json:",inline"`
+}
+
+const (
+	// ScheduleTemplateKind is the kind of namespaced schedule templates.
+	ScheduleTemplateKind = "ScheduleTemplate"
+	// ClusterScheduleTemplateKind is the kind of cluster-scoped schedule templates.
+	ClusterScheduleTemplateKind = "ClusterScheduleTemplate"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=spt
+
+// ScheduleTemplate is the Schema for the scheduletemplates API
+type ScheduleTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ScheduleTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ScheduleTemplateList contains a list of ScheduleTemplate
+type ScheduleTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ScheduleTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ScheduleTemplate{}, &ScheduleTemplateList{})
+}
@@ -0,0 +1,102 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var scheduletemplatelog = logf.Log.WithName("scheduletemplate-resource")
+
+func (r *ScheduleTemplate) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+func (r *ClusterScheduleTemplate) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-autoscaling-spa-sarmadabualkaz-io-v1-scheduletemplate,mutating=false,failurePolicy=fail,groups=autoscaling.spa.sarmadabualkaz.io,resources=scheduletemplates,versions=v1,name=vscheduletemplate.kb.io
+// +kubebuilder:webhook:verbs=create;update,path=/validate-autoscaling-spa-sarmadabualkaz-io-v1-clusterscheduletemplate,mutating=false,failurePolicy=fail,groups=autoscaling.spa.sarmadabualkaz.io,resources=clusterscheduletemplates,versions=v1,name=vclusterscheduletemplate.kb.io
+
+var _ webhook.Validator = &ScheduleTemplate{}
+var _ webhook.Validator = &ClusterScheduleTemplate{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ScheduleTemplate) ValidateCreate() error {
+	scheduletemplatelog.Info("validate create", "name", r.Name)
+	return validateScheduleTemplate(ScheduleTemplateKind, r.Name, r.Spec)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ScheduleTemplate) ValidateUpdate(old runtime.Object) error {
+	scheduletemplatelog.Info("validate update", "name", r.Name)
+	return validateScheduleTemplate(ScheduleTemplateKind, r.Name, r.Spec)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ScheduleTemplate) ValidateDelete() error {
+	return nil
+}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterScheduleTemplate) ValidateCreate() error {
+	scheduletemplatelog.Info("validate create", "name", r.Name, "kind", ClusterScheduleTemplateKind)
+	return validateScheduleTemplate(ClusterScheduleTemplateKind, r.Name, r.Spec)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterScheduleTemplate) ValidateUpdate(old runtime.Object) error {
+	scheduletemplatelog.Info("validate update", "name", r.Name, "kind", ClusterScheduleTemplateKind)
+	return validateScheduleTemplate(ClusterScheduleTemplateKind, r.Name, r.Spec)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterScheduleTemplate) ValidateDelete() error {
+	return nil
+}
+
+// validateScheduleTemplate checks the times, calendar and values of a template like those of an SPA - values are
+// optional, as the SPAs referencing the template may set their own.
+func validateScheduleTemplate(kind string, name string, spec ScheduleTemplateSpec) error {
+	var allErrs field.ErrorList
+	if err := validateSchedule(spec.ScaleUp, spec.ScaleDown, spec.ScheduleCalendar); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	if err := validateScaleSteps(spec.ScaleUp, spec.ScaleDown); err != nil {
+		allErrs = append(allErrs, err)
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: "autoscaling.spa.sarmadabualkaz.io", Kind: kind},
+		name, allErrs)
+}
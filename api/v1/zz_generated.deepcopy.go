@@ -21,20 +21,476 @@ limitations under the License.
 package v1
 
 import (
+	"k8s.io/api/autoscaling/v2beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Baseline) DeepCopyInto(out *Baseline) {
+	*out = *in
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxReplicas != nil {
+		in, out := &in.MaxReplicas, &out.MaxReplicas
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Baseline.
+func (in *Baseline) DeepCopy() *Baseline {
+	if in == nil {
+		return nil
+	}
+	out := new(Baseline)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CapacityProfile) DeepCopyInto(out *CapacityProfile) {
+	*out = *in
+	if in.MaxReplicas != nil {
+		in, out := &in.MaxReplicas, &out.MaxReplicas
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CapacityProfile.
+func (in *CapacityProfile) DeepCopy() *CapacityProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(CapacityProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterScheduleTemplate) DeepCopyInto(out *ClusterScheduleTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterScheduleTemplate.
+func (in *ClusterScheduleTemplate) DeepCopy() *ClusterScheduleTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterScheduleTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterScheduleTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterScheduleTemplateList) DeepCopyInto(out *ClusterScheduleTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterScheduleTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterScheduleTemplateList.
+func (in *ClusterScheduleTemplateList) DeepCopy() *ClusterScheduleTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterScheduleTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterScheduleTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterScheduledPodAutoscaler) DeepCopyInto(out *ClusterScheduledPodAutoscaler) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterScheduledPodAutoscaler.
+func (in *ClusterScheduledPodAutoscaler) DeepCopy() *ClusterScheduledPodAutoscaler {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterScheduledPodAutoscaler)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterScheduledPodAutoscaler) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterScheduledPodAutoscalerList) DeepCopyInto(out *ClusterScheduledPodAutoscalerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterScheduledPodAutoscaler, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterScheduledPodAutoscalerList.
+func (in *ClusterScheduledPodAutoscalerList) DeepCopy() *ClusterScheduledPodAutoscalerList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterScheduledPodAutoscalerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterScheduledPodAutoscalerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterScheduledPodAutoscalerSpec) DeepCopyInto(out *ClusterScheduledPodAutoscalerSpec) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterScheduledPodAutoscalerSpec.
+func (in *ClusterScheduledPodAutoscalerSpec) DeepCopy() *ClusterScheduledPodAutoscalerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterScheduledPodAutoscalerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterScheduledPodAutoscalerStatus) DeepCopyInto(out *ClusterScheduledPodAutoscalerStatus) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterScheduledPodAutoscalerStatus.
+func (in *ClusterScheduledPodAutoscalerStatus) DeepCopy() *ClusterScheduledPodAutoscalerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterScheduledPodAutoscalerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContentionPolicy) DeepCopyInto(out *ContentionPolicy) {
+	*out = *in
+	if in.BackoffDuration != nil {
+		in, out := &in.BackoffDuration, &out.BackoffDuration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContentionPolicy.
+func (in *ContentionPolicy) DeepCopy() *ContentionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ContentionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GroupMember) DeepCopyInto(out *GroupMember) {
+	*out = *in
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GroupMember.
+func (in *GroupMember) DeepCopy() *GroupMember {
+	if in == nil {
+		return nil
+	}
+	out := new(GroupMember)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GroupStageStatus) DeepCopyInto(out *GroupStageStatus) {
+	*out = *in
+	if in.Members != nil {
+		in, out := &in.Members, &out.Members
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GroupStageStatus.
+func (in *GroupStageStatus) DeepCopy() *GroupStageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GroupStageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GroupStep) DeepCopyInto(out *GroupStep) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GroupStep.
+func (in *GroupStep) DeepCopy() *GroupStep {
+	if in == nil {
+		return nil
+	}
+	out := new(GroupStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HPAProfile) DeepCopyInto(out *HPAProfile) {
+	*out = *in
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = make([]v2beta2.MetricSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Behavior != nil {
+		in, out := &in.Behavior, &out.Behavior
+		*out = new(v2beta2.HorizontalPodAutoscalerBehavior)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HPAProfile.
+func (in *HPAProfile) DeepCopy() *HPAProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(HPAProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamedTargetStatus) DeepCopyInto(out *NamedTargetStatus) {
+	*out = *in
+	in.TargetStatus.DeepCopyInto(&out.TargetStatus)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamedTargetStatus.
+func (in *NamedTargetStatus) DeepCopy() *NamedTargetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NamedTargetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlaceholderPolicy) DeepCopyInto(out *PlaceholderPolicy) {
+	*out = *in
+	if in.Ahead != nil {
+		in, out := &in.Ahead, &out.Ahead
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlaceholderPolicy.
+func (in *PlaceholderPolicy) DeepCopy() *PlaceholderPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PlaceholderPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreflightPolicy) DeepCopyInto(out *PreflightPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreflightPolicy.
+func (in *PreflightPolicy) DeepCopy() *PreflightPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PreflightPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfilePin) DeepCopyInto(out *ProfilePin) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfilePin.
+func (in *ProfilePin) DeepCopy() *ProfilePin {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfilePin)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReplicaLink) DeepCopyInto(out *ReplicaLink) {
+	*out = *in
+	if in.Ratio != nil {
+		in, out := &in.Ratio, &out.Ratio
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxReplicas != nil {
+		in, out := &in.MaxReplicas, &out.MaxReplicas
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicaLink.
+func (in *ReplicaLink) DeepCopy() *ReplicaLink {
+	if in == nil {
+		return nil
+	}
+	out := new(ReplicaLink)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReplicaSample) DeepCopyInto(out *ReplicaSample) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicaSample.
+func (in *ReplicaSample) DeepCopy() *ReplicaSample {
+	if in == nil {
+		return nil
+	}
+	out := new(ReplicaSample)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Resource) DeepCopyInto(out *Resource) {
 	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Resource.
+func (in *Resource) DeepCopy() *Resource {
+	if in == nil {
+		return nil
+	}
+	out := new(Resource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScaleDownStrategy) DeepCopyInto(out *ScaleDownStrategy) {
+	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Resource.
-func (in *Resource) DeepCopy() *Resource {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScaleDownStrategy.
+func (in *ScaleDownStrategy) DeepCopy() *ScaleDownStrategy {
 	if in == nil {
 		return nil
 	}
-	out := new(Resource)
+	out := new(ScaleDownStrategy)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -44,9 +500,24 @@ func (in *ScaleSpec) DeepCopyInto(out *ScaleSpec) {
 	*out = *in
 	if in.Value != nil {
 		in, out := &in.Value, &out.Value
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.Link != nil {
+		in, out := &in.Link, &out.Link
+		*out = new(ReplicaLink)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxReplicas != nil {
+		in, out := &in.MaxReplicas, &out.MaxReplicas
 		*out = new(int32)
 		**out = **in
 	}
+	if in.HPAProfile != nil {
+		in, out := &in.HPAProfile, &out.HPAProfile
+		*out = new(HPAProfile)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScaleSpec.
@@ -59,6 +530,122 @@ func (in *ScaleSpec) DeepCopy() *ScaleSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduleCalendar) DeepCopyInto(out *ScheduleCalendar) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]Day, len(*in))
+		copy(*out, *in)
+	}
+	if in.Holidays != nil {
+		in, out := &in.Holidays, &out.Holidays
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduleCalendar.
+func (in *ScheduleCalendar) DeepCopy() *ScheduleCalendar {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduleCalendar)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduleTemplate) DeepCopyInto(out *ScheduleTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduleTemplate.
+func (in *ScheduleTemplate) DeepCopy() *ScheduleTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduleTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ScheduleTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduleTemplateList) DeepCopyInto(out *ScheduleTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ScheduleTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduleTemplateList.
+func (in *ScheduleTemplateList) DeepCopy() *ScheduleTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduleTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ScheduleTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduleTemplateRef) DeepCopyInto(out *ScheduleTemplateRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduleTemplateRef.
+func (in *ScheduleTemplateRef) DeepCopy() *ScheduleTemplateRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduleTemplateRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduleTemplateSpec) DeepCopyInto(out *ScheduleTemplateSpec) {
+	*out = *in
+	in.ScaleUp.DeepCopyInto(&out.ScaleUp)
+	in.ScaleDown.DeepCopyInto(&out.ScaleDown)
+	in.ScheduleCalendar.DeepCopyInto(&out.ScheduleCalendar)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduleTemplateSpec.
+func (in *ScheduleTemplateSpec) DeepCopy() *ScheduleTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduleTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScheduledPodAutoscaler) DeepCopyInto(out *ScheduledPodAutoscaler) {
 	*out = *in
@@ -121,9 +708,62 @@ func (in *ScheduledPodAutoscalerList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScheduledPodAutoscalerSpec) DeepCopyInto(out *ScheduledPodAutoscalerSpec) {
 	*out = *in
-	out.Resource = in.Resource
+	in.Resource.DeepCopyInto(&out.Resource)
+	if in.TemplateRef != nil {
+		in, out := &in.TemplateRef, &out.TemplateRef
+		*out = new(ScheduleTemplateRef)
+		**out = **in
+	}
 	in.ScaleUp.DeepCopyInto(&out.ScaleUp)
 	in.ScaleDown.DeepCopyInto(&out.ScaleDown)
+	in.ScheduleCalendar.DeepCopyInto(&out.ScheduleCalendar)
+	if in.Baseline != nil {
+		in, out := &in.Baseline, &out.Baseline
+		*out = new(Baseline)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Profiles != nil {
+		in, out := &in.Profiles, &out.Profiles
+		*out = make([]CapacityProfile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Pin != nil {
+		in, out := &in.Pin, &out.Pin
+		*out = new(ProfilePin)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OnContention != nil {
+		in, out := &in.OnContention, &out.OnContention
+		*out = new(ContentionPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ManualOverrideGracePeriod != nil {
+		in, out := &in.ManualOverrideGracePeriod, &out.ManualOverrideGracePeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ReadinessDeadline != nil {
+		in, out := &in.ReadinessDeadline, &out.ReadinessDeadline
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Placeholders != nil {
+		in, out := &in.Placeholders, &out.Placeholders
+		*out = new(PlaceholderPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Preflight != nil {
+		in, out := &in.Preflight, &out.Preflight
+		*out = new(PreflightPolicy)
+		**out = **in
+	}
+	if in.ScaleDownStrategy != nil {
+		in, out := &in.ScaleDownStrategy, &out.ScaleDownStrategy
+		*out = new(ScaleDownStrategy)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledPodAutoscalerSpec.
@@ -139,10 +779,23 @@ func (in *ScheduledPodAutoscalerSpec) DeepCopy() *ScheduledPodAutoscalerSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScheduledPodAutoscalerStatus) DeepCopyInto(out *ScheduledPodAutoscalerStatus) {
 	*out = *in
-	if in.LastScheduleTime != nil {
-		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+	in.TargetStatus.DeepCopyInto(&out.TargetStatus)
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]NamedTargetStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PinExpirationTime != nil {
+		in, out := &in.PinExpirationTime, &out.PinExpirationTime
 		*out = (*in).DeepCopy()
 	}
+	if in.EffectiveLeadTime != nil {
+		in, out := &in.EffectiveLeadTime, &out.EffectiveLeadTime
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledPodAutoscalerStatus.
@@ -154,3 +807,209 @@ func (in *ScheduledPodAutoscalerStatus) DeepCopy() *ScheduledPodAutoscalerStatus
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledScalingGroup) DeepCopyInto(out *ScheduledScalingGroup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledScalingGroup.
+func (in *ScheduledScalingGroup) DeepCopy() *ScheduledScalingGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledScalingGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ScheduledScalingGroup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledScalingGroupList) DeepCopyInto(out *ScheduledScalingGroupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ScheduledScalingGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledScalingGroupList.
+func (in *ScheduledScalingGroupList) DeepCopy() *ScheduledScalingGroupList {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledScalingGroupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ScheduledScalingGroupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledScalingGroupSpec) DeepCopyInto(out *ScheduledScalingGroupSpec) {
+	*out = *in
+	out.ScaleUp = in.ScaleUp
+	out.ScaleDown = in.ScaleDown
+	in.ScheduleCalendar.DeepCopyInto(&out.ScheduleCalendar)
+	if in.Members != nil {
+		in, out := &in.Members, &out.Members
+		*out = make([]GroupMember, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StageTimeout != nil {
+		in, out := &in.StageTimeout, &out.StageTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledScalingGroupSpec.
+func (in *ScheduledScalingGroupSpec) DeepCopy() *ScheduledScalingGroupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledScalingGroupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledScalingGroupStatus) DeepCopyInto(out *ScheduledScalingGroupStatus) {
+	*out = *in
+	if in.StepStartTime != nil {
+		in, out := &in.StepStartTime, &out.StepStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]GroupStageStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledScalingGroupStatus.
+func (in *ScheduledScalingGroupStatus) DeepCopy() *ScheduledScalingGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledScalingGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetStatus) DeepCopyInto(out *TargetStatus) {
+	*out = *in
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastAppliedReplicas != nil {
+		in, out := &in.LastAppliedReplicas, &out.LastAppliedReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ManualOverrideTime != nil {
+		in, out := &in.ManualOverrideTime, &out.ManualOverrideTime
+		*out = (*in).DeepCopy()
+	}
+	if in.OriginalMaxReplicas != nil {
+		in, out := &in.OriginalMaxReplicas, &out.OriginalMaxReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.OriginalReplicas != nil {
+		in, out := &in.OriginalReplicas, &out.OriginalReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ReplicaHistory != nil {
+		in, out := &in.ReplicaHistory, &out.ReplicaHistory
+		*out = make([]ReplicaSample, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OriginalTargetReplicas != nil {
+		in, out := &in.OriginalTargetReplicas, &out.OriginalTargetReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.OriginalHPAProfile != nil {
+		in, out := &in.OriginalHPAProfile, &out.OriginalHPAProfile
+		*out = new(HPAProfile)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ScaleUpTime != nil {
+		in, out := &in.ScaleUpTime, &out.ScaleUpTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpectedReadyReplicas != nil {
+		in, out := &in.ExpectedReadyReplicas, &out.ExpectedReadyReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TimesToReady != nil {
+		in, out := &in.TimesToReady, &out.TimesToReady
+		*out = make([]metav1.Duration, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeletionCostTime != nil {
+		in, out := &in.DeletionCostTime, &out.DeletionCostTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastContestedTime != nil {
+		in, out := &in.LastContestedTime, &out.LastContestedTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetStatus.
+func (in *TargetStatus) DeepCopy() *TargetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,151 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var (
+	// replicaHistoryRetention is how long samples of the replica history are kept:
+	replicaHistoryRetention = 25 * time.Hour
+
+	// yesterdayTolerance is how far off a sample may be from the start of the active step yesterday to be used:
+	yesterdayTolerance = time.Hour
+)
+
+// observedReplicas returns the replicas the target runs (for HPAs: the replicas the HPA chose) - nil if unknown.
+func (t *scaleTarget) observedReplicas() *int32 {
+	switch t.resourceType {
+	case "hpa":
+		return t.readField([]string{"status", "currentReplicas"})
+	case "deployment", "statefulset", "hpaOperator":
+		return t.readField([]string{"spec", "replicas"})
+	}
+	return t.replicas()
+}
+
+// autoscaled checks whether an autoscaler chooses the replicas of the target (HPAs, and Deployments scaled by the HPA
+// of the HPA-operator) - the replicas of any other target are the ones the SPA itself applied.
+func (t *scaleTarget) autoscaled() bool {
+	return t.resourceType == "hpa" || t.resourceType == "hpaOperator"
+}
+
+// recordBaseline records what percentage values may be relative to: the replicas of the target when first seen,
+// and (for the Yesterday baseline of autoscaled targets only) the replica history. A history of replicas the SPA
+// applied itself would make percentages compound day over day - those targets fall back to the Original baseline.
+func recordBaseline(target *scaleTarget, status *autoscalingv1.TargetStatus, baseline *autoscalingv1.Baseline, stepStart time.Time, now time.Time) {
+	observed := target.observedReplicas()
+	if observed == nil {
+		return
+	}
+
+	if status.OriginalReplicas == nil {
+		replicas := *observed
+		status.OriginalReplicas = &replicas
+	}
+
+	if baseline == nil || baseline.Source != autoscalingv1.BaselineYesterday || !target.autoscaled() {
+		status.ReplicaHistory = nil
+		return
+	}
+	status.ReplicaHistory = recordReplicaSample(status.ReplicaHistory, *observed, stepStart, now)
+}
+
+// recordReplicaSample appends a sample to the history once per step - at its first reconcile, before the step is
+// applied, as long as that is within yesterdayTolerance of its start (only such samples are ever used) - and drops
+// the samples older than replicaHistoryRetention. This keeps a few samples per day at most.
+func recordReplicaSample(history []autoscalingv1.ReplicaSample, replicas int32, stepStart time.Time, now time.Time) []autoscalingv1.ReplicaSample {
+	kept := make([]autoscalingv1.ReplicaSample, 0, len(history)+1)
+	for _, sample := range history {
+		if now.Sub(sample.Time.Time) <= replicaHistoryRetention {
+			kept = append(kept, sample)
+		}
+	}
+
+	if now.Sub(stepStart) > yesterdayTolerance || (len(kept) > 0 && !kept[len(kept)-1].Time.Time.Before(stepStart)) {
+		return kept
+	}
+	return append(kept, autoscalingv1.ReplicaSample{Time: metav1.Time{Time: now}, Replicas: replicas})
+}
+
+// replicasAt returns the replicas of the sample closest to the given time - nil if there is none within yesterdayTolerance.
+func replicasAt(history []autoscalingv1.ReplicaSample, at time.Time) *int32 {
+	var closest *autoscalingv1.ReplicaSample
+	var closestOff time.Duration
+
+	for i := range history {
+		off := history[i].Time.Sub(at)
+		if off < 0 {
+			off = -off
+		}
+
+		if off <= yesterdayTolerance && (closest == nil || off < closestOff) {
+			closest, closestOff = &history[i], off
+		}
+	}
+
+	if closest == nil {
+		return nil
+	}
+	replicas := closest.Replicas
+	return &replicas
+}
+
+// baselineReplicas returns the replicas percentage values are relative to - for the Yesterday baseline the replicas
+// when the active step started yesterday, falling back to the Original baseline when there is no sample of it.
+func baselineReplicas(status *autoscalingv1.TargetStatus, baseline *autoscalingv1.Baseline, stepStart time.Time) (int32, error) {
+	if baseline != nil && baseline.Source == autoscalingv1.BaselineYesterday {
+		if replicas := replicasAt(status.ReplicaHistory, stepStart.AddDate(0, 0, -1)); replicas != nil {
+			return *replicas, nil
+		}
+	}
+
+	if status.OriginalReplicas == nil {
+		return 0, fmt.Errorf("the replicas percentage values are relative to are not known yet")
+	}
+	return *status.OriginalReplicas, nil
+}
+
+// scaledReplicas works out the replicas of a scale value: numbers are used as they are, while percentages are taken
+// of the baseline replicas, rounded up (so any percentage above 0% of a non-zero baseline is at least 1 replica)
+// and then clamped to baseline.minReplicas and baseline.maxReplicas.
+func scaledReplicas(value intstr.IntOrString, baselineReplicas int32, baseline *autoscalingv1.Baseline) (int32, error) {
+	if value.Type == intstr.Int {
+		return value.IntVal, nil
+	}
+
+	scaled, err := intstr.GetValueFromIntOrPercent(&value, int(baselineReplicas), true)
+	if err != nil {
+		return 0, err
+	}
+
+	replicas := int32(scaled)
+	if baseline != nil && baseline.MinReplicas != nil && replicas < *baseline.MinReplicas {
+		replicas = *baseline.MinReplicas
+	}
+	if baseline != nil && baseline.MaxReplicas != nil && replicas > *baseline.MaxReplicas {
+		replicas = *baseline.MaxReplicas
+	}
+	return replicas, nil
+}
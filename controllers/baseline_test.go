@@ -0,0 +1,134 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var _ = Describe("Percentage values", func() {
+	int32Ptr := func(i int32) *int32 { return &i }
+
+	DescribeTable("scaledReplicas rounds up and clamps percentages",
+		func(value intstr.IntOrString, baselineReplicas int32, baseline *autoscalingv1.Baseline, expected int32) {
+			replicas, err := scaledReplicas(value, baselineReplicas, baseline)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replicas).To(Equal(expected))
+		},
+		Entry("numbers are used as they are", intstr.FromInt(7), int32(10), nil, int32(7)),
+		Entry("numbers are not clamped", intstr.FromInt(7), int32(10), &autoscalingv1.Baseline{MaxReplicas: int32Ptr(5)}, int32(7)),
+		Entry("exact percentages", intstr.FromString("150%"), int32(10), nil, int32(15)),
+		Entry("fractions are rounded up", intstr.FromString("50%"), int32(5), nil, int32(3)),
+		Entry("small percentages of a non-zero baseline are at least 1", intstr.FromString("1%"), int32(3), nil, int32(1)),
+		Entry("0% is 0", intstr.FromString("0%"), int32(10), nil, int32(0)),
+		Entry("percentages of a zero baseline are 0", intstr.FromString("150%"), int32(0), nil, int32(0)),
+		Entry("clamped to minReplicas", intstr.FromString("10%"), int32(10), &autoscalingv1.Baseline{MinReplicas: int32Ptr(2)}, int32(2)),
+		Entry("clamped to maxReplicas", intstr.FromString("300%"), int32(10), &autoscalingv1.Baseline{MaxReplicas: int32Ptr(20)}, int32(20)),
+		Entry("within the clamps", intstr.FromString("150%"), int32(10), &autoscalingv1.Baseline{MinReplicas: int32Ptr(2), MaxReplicas: int32Ptr(20)}, int32(15)),
+	)
+
+	It("rejects values that are no percentage", func() {
+		_, err := scaledReplicas(intstr.FromString("lots"), 10, nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("with the Yesterday baseline", func() {
+		yesterday := &autoscalingv1.Baseline{Source: autoscalingv1.BaselineYesterday}
+		stepStart := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+
+		It("uses the sample closest to the start of the step yesterday", func() {
+			status := &autoscalingv1.TargetStatus{
+				OriginalReplicas: int32Ptr(4),
+				ReplicaHistory: []autoscalingv1.ReplicaSample{
+					{Time: metav1.NewTime(stepStart.Add(-25 * time.Hour)), Replicas: 6},
+					{Time: metav1.NewTime(stepStart.Add(-24*time.Hour + 5*time.Minute)), Replicas: 12},
+					{Time: metav1.NewTime(stepStart.Add(-23 * time.Hour)), Replicas: 20},
+				},
+			}
+
+			replicas, err := baselineReplicas(status, yesterday, stepStart)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replicas).To(Equal(int32(12)))
+		})
+
+		It("falls back to the original replicas without a sample of yesterday", func() {
+			status := &autoscalingv1.TargetStatus{
+				OriginalReplicas: int32Ptr(4),
+				ReplicaHistory: []autoscalingv1.ReplicaSample{
+					{Time: metav1.NewTime(stepStart.Add(-3 * time.Hour)), Replicas: 9},
+				},
+			}
+
+			replicas, err := baselineReplicas(status, yesterday, stepStart)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replicas).To(Equal(int32(4)))
+		})
+
+		It("fails without any baseline", func() {
+			_, err := baselineReplicas(&autoscalingv1.TargetStatus{}, yesterday, stepStart)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	It("samples the replica history once per step, at its start, for replicaHistoryRetention", func() {
+		stepStart := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+		history := []autoscalingv1.ReplicaSample{
+			{Time: metav1.NewTime(stepStart.Add(-26 * time.Hour)), Replicas: 1},
+			{Time: metav1.NewTime(stepStart.Add(-14 * time.Hour)), Replicas: 2},
+		}
+
+		history = recordReplicaSample(history, 3, stepStart, stepStart.Add(time.Minute))
+		Expect(history).To(HaveLen(2))
+		Expect(history[1].Replicas).To(Equal(int32(3)))
+
+		// later reconciles of the same step don't sample again - the step may already have changed the replicas:
+		history = recordReplicaSample(history, 4, stepStart, stepStart.Add(20*time.Minute))
+		Expect(history).To(HaveLen(2))
+		Expect(history[1].Replicas).To(Equal(int32(3)))
+	})
+
+	It("doesn't sample a step first reconciled more than yesterdayTolerance after its start", func() {
+		stepStart := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+		history := recordReplicaSample(nil, 3, stepStart, stepStart.Add(yesterdayTolerance+time.Minute))
+		Expect(history).To(BeEmpty())
+	})
+
+	DescribeTable("recordBaseline only keeps a replica history of autoscaled targets",
+		func(resourceType string, object map[string]interface{}, expectedHistory int) {
+			stepStart := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+			target := &scaleTarget{resourceType: resourceType, object: &unstructured.Unstructured{Object: object}, scaledField: []string{"spec", "replicas"}}
+			status := &autoscalingv1.TargetStatus{}
+
+			recordBaseline(target, status, &autoscalingv1.Baseline{Source: autoscalingv1.BaselineYesterday}, stepStart, stepStart)
+			Expect(status.OriginalReplicas).NotTo(BeNil())
+			Expect(status.ReplicaHistory).To(HaveLen(expectedHistory))
+		},
+		Entry("an HPA - the replicas it chose", "hpa", map[string]interface{}{"status": map[string]interface{}{"currentReplicas": int64(6)}}, 1),
+		Entry("a Deployment of the HPA-operator", "hpaOperator", map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(6)}}, 1),
+		Entry("a Deployment scaled by the SPA itself", "deployment", map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(6)}}, 0),
+		Entry("a StatefulSet scaled by the SPA itself", "statefulset", map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(6)}}, 0),
+	)
+})
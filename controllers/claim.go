@@ -0,0 +1,75 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+// scaleClaims maps the resources of the namespace scaled by an SPA or a ScheduledScalingGroup - as <kind>/<name> - to
+// the objects scaling them, e.g. "ScheduledPodAutoscaler/web". The SPA named except is left out (as are Hibernate
+// mode SPAs, which claim nothing), and so are SPAs whose targets can't be resolved - they report that themselves.
+func (r *ScheduledPodAutoscalerReconciler) scaleClaims(ctx context.Context, namespace string, except string) (map[string][]string, error) {
+	claims := map[string][]string{}
+
+	var spas autoscalingv1.ScheduledPodAutoscalerList
+	if err := r.List(ctx, &spas, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	for i := range spas.Items {
+		spa := &spas.Items[i]
+		resourceType, err := normalizedResourceType(spa)
+		if spa.Name == except || err != nil || resourceType == "hibernate" {
+			continue
+		}
+
+		var targets []*scaleTarget
+		if spa.Spec.Resource.Selector != nil {
+			targets, err = r.listScaleTargets(ctx, resourceType, spa.Spec.Resource, namespace)
+		} else {
+			var target *scaleTarget
+			target, err = r.newScaleTarget(resourceType, spa.Spec.Resource, client.ObjectKey{Name: spa.Spec.Resource.Name, Namespace: namespace})
+			targets = []*scaleTarget{target}
+		}
+		if err != nil {
+			continue
+		}
+
+		for _, target := range targets {
+			resource := target.gvk.Kind + "/" + target.key.Name
+			claims[resource] = append(claims[resource], "ScheduledPodAutoscaler/"+spa.Name)
+		}
+	}
+
+	var groups autoscalingv1.ScheduledScalingGroupList
+	if err := r.List(ctx, &groups, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups.Items {
+		for _, member := range group.Spec.Members {
+			resource := groupMemberKey(member)
+			claims[resource] = append(claims[resource], "ScheduledScalingGroup/"+group.Name)
+		}
+	}
+	return claims, nil
+}
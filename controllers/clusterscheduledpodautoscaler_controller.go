@@ -0,0 +1,241 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+// ClusterScheduledPodAutoscalerReconciler reconciles a ClusterScheduledPodAutoscaler object
+type ClusterScheduledPodAutoscalerReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=autoscaling.spa.sarmadabualkaz.io,resources=clusterscheduledpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=autoscaling.spa.sarmadabualkaz.io,resources=clusterscheduledpodautoscalers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+var (
+	// clusterPolicyLabel marks the SPAs created from a ClusterScheduledPodAutoscaler - its value is the ClusterSPA's name:
+	clusterPolicyLabel = "spa.sarmadabualkaz.io/cluster-policy"
+
+	// clusterPolicyPrefix is put in front of the ClusterSPA's name to name the SPAs created from it:
+	clusterPolicyPrefix = "cluster-"
+
+	namespaceListGVK = schema.GroupVersionKind{
+		Group:   "",
+		Kind:    "NamespaceList",
+		Version: "v1",
+	}
+)
+
+// Reconcile keeps an SPA created from the ClusterSPA's template in every selected namespace - the ClusterSPA doesn't scale
+// anything itself, the SPAs are scaled by the ScheduledPodAutoscaler controller like any other SPA.
+func (r *ClusterScheduledPodAutoscalerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("clusterscheduledpodautoscaler", req.NamespacedName)
+
+	// 1. Load the named ClusterSPA (ClusterScheduledPodAutoscaler):
+	var clusterScheduledPodAutoscaler autoscalingv1.ClusterScheduledPodAutoscaler
+	if err := r.Get(ctx, req.NamespacedName, &clusterScheduledPodAutoscaler); err != nil {
+		log.Error(err, "unable to fetch ClusterScheduledPodAutoscaler")
+		// SPAs created from a deleted ClusterSPA are garbage collected through their owner reference:
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// 2. List the selected namespaces:
+	selector := labels.Everything()
+	if clusterScheduledPodAutoscaler.Spec.NamespaceSelector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(clusterScheduledPodAutoscaler.Spec.NamespaceSelector); err != nil {
+			log.Error(err, "invalid namespaceSelector")
+			return ctrl.Result{}, err
+		}
+	}
+
+	namespaces := &unstructured.UnstructuredList{}
+	namespaces.SetGroupVersionKind(namespaceListGVK)
+
+	if err := r.List(ctx, namespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		log.Error(err, "unable to list namespaces")
+		return ctrl.Result{}, err
+	}
+
+	// 3. Create (or update) the SPA of every selected namespace - SPAs of the same name that weren't created from the
+	// ClusterSPA are left as they are:
+	spaName := clusterPolicyPrefix + clusterScheduledPodAutoscaler.Name
+	selected := make(map[string]bool, len(namespaces.Items))
+	failed := make(map[string]bool)
+	var conflicts []string
+	var errs []error
+
+	for _, namespace := range namespaces.Items {
+		// namespaces being deleted can't get new SPAs:
+		if namespace.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		spa := &autoscalingv1.ScheduledPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: spaName, Namespace: namespace.GetName()}}
+		result, err := controllerutil.CreateOrUpdate(ctx, r.Client, spa, func() error {
+			if spa.ResourceVersion != "" && !createdFrom(spa, &clusterScheduledPodAutoscaler) {
+				return errNotCreatedFrom
+			}
+
+			if spa.Labels == nil {
+				spa.Labels = map[string]string{}
+			}
+			spa.Labels[clusterPolicyLabel] = clusterScheduledPodAutoscaler.Name
+
+			// default the spec as the SPA webhook would - otherwise every update is defaulted to a spec differing from the template:
+			spa.Spec = *clusterScheduledPodAutoscaler.Spec.Template.DeepCopy()
+			spa.Default()
+
+			return controllerutil.SetControllerReference(&clusterScheduledPodAutoscaler, spa, r.Scheme)
+		})
+		if err == errNotCreatedFrom {
+			log.V(1).Info("Namespace already holds an SPA of the same name - leaving it alone", "namespace", namespace.GetName(), "spa", spaName)
+			conflicts = append(conflicts, namespace.GetName())
+			continue
+		} else if err != nil {
+			// keep going - one namespace rejecting the SPA (e.g. through a quota or a webhook) mustn't hold up the others:
+			log.Error(err, "unable to create or update SPA", "namespace", namespace.GetName())
+			errs = append(errs, fmt.Errorf("namespace %s: %w", namespace.GetName(), err))
+			failed[namespace.GetName()] = true
+			continue
+		}
+		if result != controllerutil.OperationResultNone {
+			log.V(1).Info("SPA brought in line with the template", "namespace", namespace.GetName(), "operation", result)
+		}
+		selected[namespace.GetName()] = true
+	}
+
+	// 4. Delete the SPAs of namespaces that are no longer selected:
+	var spas autoscalingv1.ScheduledPodAutoscalerList
+	if err := r.List(ctx, &spas, client.MatchingLabels{clusterPolicyLabel: clusterScheduledPodAutoscaler.Name}); err != nil {
+		log.Error(err, "unable to list SPAs created from ClusterScheduledPodAutoscaler")
+		return ctrl.Result{}, err
+	}
+
+	for i := range spas.Items {
+		if selected[spas.Items[i].Namespace] || failed[spas.Items[i].Namespace] || !createdFrom(&spas.Items[i], &clusterScheduledPodAutoscaler) {
+			continue
+		}
+
+		log.V(1).Info("Namespace is no longer selected - deleting its SPA", "namespace", spas.Items[i].Namespace)
+		if err := r.Delete(ctx, &spas.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			log.Error(err, "unable to delete SPA", "namespace", spas.Items[i].Namespace)
+			return ctrl.Result{}, err
+		}
+	}
+
+	// 5. Update status (only when it changed - the ClusterSPA is reconciled on every change of its SPAs):
+	previous := clusterScheduledPodAutoscaler.Status.DeepCopy()
+	clusterScheduledPodAutoscaler.Status.Namespaces = make([]string, 0, len(selected))
+	for namespace := range selected {
+		clusterScheduledPodAutoscaler.Status.Namespaces = append(clusterScheduledPodAutoscaler.Status.Namespaces, namespace)
+	}
+	sort.Strings(clusterScheduledPodAutoscaler.Status.Namespaces)
+
+	conflict := metav1.Condition{
+		Type:    autoscalingv1.ConditionConflict,
+		Status:  metav1.ConditionFalse,
+		Reason:  "NoConflict",
+		Message: "every selected namespace holds an SPA created from the template",
+	}
+	if len(conflicts) > 0 {
+		conflict.Status, conflict.Reason = metav1.ConditionTrue, "SPAExists"
+		conflict.Message = fmt.Sprintf("SPA %s already exists (not created from the template) in %s", spaName, strings.Join(conflicts, ", "))
+	}
+	meta.SetStatusCondition(&clusterScheduledPodAutoscaler.Status.Conditions, conflict)
+
+	if !equality.Semantic.DeepEqual(previous, &clusterScheduledPodAutoscaler.Status) {
+		if err := r.Status().Update(ctx, &clusterScheduledPodAutoscaler); err != nil {
+			log.Error(err, "unable to update ClusterScheduledPodAutoscaler status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// the namespaces the SPA couldn't be created or updated in are retried with backoff:
+	if len(errs) > 0 {
+		return ctrl.Result{}, utilerrors.NewAggregate(errs)
+	}
+
+	// 6. Requeue reconciliation (at the same rate as SPAs) and return to manager:
+	return ctrl.Result{RequeueAfter: requeueRate(log)}, nil
+}
+
+// errNotCreatedFrom is returned when an SPA wasn't created from the ClusterSPA it would be updated from.
+var errNotCreatedFrom = errors.New("SPA wasn't created from the ClusterScheduledPodAutoscaler")
+
+// createdFrom checks whether the SPA was created from the ClusterSPA - i.e. carries its label and is controlled by it.
+func createdFrom(spa *autoscalingv1.ScheduledPodAutoscaler, clusterScheduledPodAutoscaler *autoscalingv1.ClusterScheduledPodAutoscaler) bool {
+	return spa.Labels[clusterPolicyLabel] == clusterScheduledPodAutoscaler.Name && metav1.IsControlledBy(spa, clusterScheduledPodAutoscaler)
+}
+
+// allClusterScheduledPodAutoscalers maps a namespace change to every ClusterSPA, so new (or relabeled) namespaces are picked up right away.
+func (r *ClusterScheduledPodAutoscalerReconciler) allClusterScheduledPodAutoscalers(obj client.Object) []reconcile.Request {
+	var clusterScheduledPodAutoscalers autoscalingv1.ClusterScheduledPodAutoscalerList
+	if err := r.List(context.Background(), &clusterScheduledPodAutoscalers); err != nil {
+		r.Log.Error(err, "unable to list ClusterScheduledPodAutoscalers")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(clusterScheduledPodAutoscalers.Items))
+	for _, cspa := range clusterScheduledPodAutoscalers.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: cspa.Name}})
+	}
+	return requests
+}
+
+func (r *ClusterScheduledPodAutoscalerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	namespace := &unstructured.Unstructured{}
+	namespace.SetGroupVersionKind(schema.GroupVersionKind{Group: "", Kind: "Namespace", Version: "v1"})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		// status updates (of the ClusterSPA and of its SPAs every requeue) don't need to bring the SPAs in line again:
+		For(&autoscalingv1.ClusterScheduledPodAutoscaler{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&autoscalingv1.ScheduledPodAutoscaler{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: namespace}, handler.EnqueueRequestsFromMapFunc(r.allClusterScheduledPodAutoscalers)).
+		Complete(r)
+}
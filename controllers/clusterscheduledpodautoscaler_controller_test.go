@@ -0,0 +1,182 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var _ = Describe("ClusterScheduledPodAutoscaler controller", func() {
+	ctx := context.Background()
+
+	reconciler := func() *ClusterScheduledPodAutoscalerReconciler {
+		return &ClusterScheduledPodAutoscalerReconciler{
+			Client: k8sClient,
+			Log:    logf.Log.WithName("controllers").WithName("ClusterScheduledPodAutoscaler"),
+			Scheme: scheme.Scheme,
+		}
+	}
+
+	reconcile := func(cspa *autoscalingv1.ClusterScheduledPodAutoscaler) {
+		_, err := reconciler().Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: cspa.Name}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: cspa.Name}, cspa)).To(Succeed())
+	}
+
+	createNamespace := func(name string, tier string) {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if tier != "" {
+			namespace.Labels = map[string]string{"tier": tier}
+		}
+		Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+	}
+
+	setTier := func(name string, tier string) {
+		namespace := &corev1.Namespace{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name}, namespace)).To(Succeed())
+		namespace.Labels = map[string]string{"tier": tier}
+		Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+	}
+
+	getSPA := func(namespace string, name string) (*autoscalingv1.ScheduledPodAutoscaler, error) {
+		spa := &autoscalingv1.ScheduledPodAutoscaler{}
+		return spa, k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, spa)
+	}
+
+	// newClusterSPA returns a ClusterSPA selecting the namespaces of tier <name> - scaling their deployments labeled schedule=<name>:
+	newClusterSPA := func(name string) *autoscalingv1.ClusterScheduledPodAutoscaler {
+		template := newTestSPA(name, autoscalingv1.Resource{
+			Type:     "deployment",
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"schedule": name}},
+		}).Spec
+
+		return &autoscalingv1.ClusterScheduledPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: autoscalingv1.ClusterScheduledPodAutoscalerSpec{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": name}},
+				Template:          template,
+			},
+		}
+	}
+
+	It("creates SPAs in selected namespaces only and deletes them once a namespace is deselected", func() {
+		createNamespace("cspa-selected", "selection")
+		createNamespace("cspa-other", "")
+
+		cspa := newClusterSPA("selection")
+		Expect(k8sClient.Create(ctx, cspa)).To(Succeed())
+		reconcile(cspa)
+
+		spa, err := getSPA("cspa-selected", "cluster-selection")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spa.Labels).To(HaveKeyWithValue(clusterPolicyLabel, "selection"))
+		Expect(metav1.IsControlledBy(spa, cspa)).To(BeTrue())
+		// the template is defaulted like the SPA webhook would - so it isn't updated on every reconcile:
+		Expect(spa.Spec.Mode).To(Equal(autoscalingv1.ModeScale))
+
+		_, err = getSPA("cspa-other", "cluster-selection")
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(cspa.Status.Namespaces).To(Equal([]string{"cspa-selected"}))
+
+		resourceVersion := spa.ResourceVersion
+		reconcile(cspa)
+		spa, err = getSPA("cspa-selected", "cluster-selection")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spa.ResourceVersion).To(Equal(resourceVersion))
+
+		By("selecting a namespace")
+		setTier("cspa-other", "selection")
+		reconcile(cspa)
+		_, err = getSPA("cspa-other", "cluster-selection")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cspa.Status.Namespaces).To(Equal([]string{"cspa-other", "cspa-selected"}))
+
+		By("deselecting a namespace")
+		setTier("cspa-selected", "internal")
+		reconcile(cspa)
+		_, err = getSPA("cspa-selected", "cluster-selection")
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(cspa.Status.Namespaces).To(Equal([]string{"cspa-other"}))
+	})
+
+	It("leaves an SPA of the same name it didn't create alone and reports the conflict", func() {
+		createNamespace("cspa-conflict", "conflict")
+
+		existing := newTestSPA("cluster-conflict", autoscalingv1.Resource{Name: "web", Type: "deployment"})
+		existing.Namespace = "cspa-conflict"
+		Expect(k8sClient.Create(ctx, existing)).To(Succeed())
+
+		cspa := newClusterSPA("conflict")
+		Expect(k8sClient.Create(ctx, cspa)).To(Succeed())
+		reconcile(cspa)
+
+		spa, err := getSPA("cspa-conflict", "cluster-conflict")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spa.Spec.Resource.Name).To(Equal("web"))
+		Expect(spa.Labels).NotTo(HaveKey(clusterPolicyLabel))
+		Expect(spa.OwnerReferences).To(BeEmpty())
+
+		Expect(cspa.Status.Namespaces).NotTo(ContainElement("cspa-conflict"))
+		Expect(meta.IsStatusConditionTrue(cspa.Status.Conditions, autoscalingv1.ConditionConflict)).To(BeTrue())
+		Expect(meta.FindStatusCondition(cspa.Status.Conditions, autoscalingv1.ConditionConflict).Message).To(ContainSubstring("cspa-conflict"))
+	})
+
+	It("leaves resources scaled by an overriding namespaced SPA to that SPA", func() {
+		createNamespace("cspa-override", "override")
+
+		for _, name := range []string{"api", "web"} {
+			deployment := newTestDeployment(name, "cspa-override", 1)
+			deployment.SetLabels(map[string]string{"schedule": "override"})
+			Expect(k8sClient.Create(ctx, deployment)).To(Succeed())
+		}
+
+		overriding := newTestSPA("web", autoscalingv1.Resource{Name: "web", Type: "deployment"})
+		overriding.Namespace = "cspa-override"
+		overriding.Spec.OverrideClusterPolicies = true
+		Expect(k8sClient.Create(ctx, overriding)).To(Succeed())
+
+		cspa := newClusterSPA("override")
+		Expect(k8sClient.Create(ctx, cspa)).To(Succeed())
+		reconcile(cspa)
+
+		_, err := newTestReconciler().Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "cluster-override", Namespace: "cspa-override"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		for name, replicas := range map[string]int64{"api": 4, "web": 1} {
+			deployment := &unstructured.Unstructured{}
+			deployment.SetGroupVersionKind(deploymentGVK)
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "cspa-override"}, deployment)).To(Succeed())
+
+			current, _, _ := unstructured.NestedInt64(deployment.Object, "spec", "replicas")
+			Expect(current).To(Equal(replicas), name)
+		}
+	})
+})
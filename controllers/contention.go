@@ -0,0 +1,105 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var (
+	// defaultContentionBackoff is used when the Backoff action has no backoffDuration set,
+	// and as the quiet period after which a contested resource is considered settled:
+	defaultContentionBackoff = time.Minute
+
+	// maxContentionBackoff caps the doubling delay of the Backoff action:
+	maxContentionBackoff = time.Hour
+)
+
+// competingFieldManager returns the name of a field manager other than the controller
+// owning the field at path according to managedFields - or "" if the field is not owned by anyone else.
+func competingFieldManager(managedFields []metav1.ManagedFieldsEntry, path ...string) string {
+	for _, entry := range managedFields {
+		if entry.Manager == fieldManager || entry.FieldsV1 == nil {
+			continue
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+
+		if ownsField(fields, path) {
+			return entry.Manager
+		}
+	}
+	return ""
+}
+
+// ownsField checks whether the field at path is part of a managedFields field set.
+func ownsField(fields map[string]interface{}, path []string) bool {
+	for i, name := range path {
+		value, ok := fields["f:"+name]
+		if !ok {
+			return false
+		}
+
+		if i == len(path)-1 {
+			return true
+		}
+
+		if fields, ok = value.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return false
+}
+
+// contentionPolicy returns the action and backoff configured on the SPA, filling in the defaults.
+func contentionPolicy(spa *autoscalingv1.ScheduledPodAutoscaler) (action string, backoff time.Duration) {
+	action = autoscalingv1.ContentionActionEnforce
+	backoff = defaultContentionBackoff
+
+	if spa.Spec.OnContention == nil {
+		return action, backoff
+	}
+
+	if spa.Spec.OnContention.Action != "" {
+		action = spa.Spec.OnContention.Action
+	}
+	if spa.Spec.OnContention.BackoffDuration != nil && spa.Spec.OnContention.BackoffDuration.Duration > 0 {
+		backoff = spa.Spec.OnContention.BackoffDuration.Duration
+	}
+	return action, backoff
+}
+
+// contentionBackoff doubles the base delay for every time the field was contested before, up to maxContentionBackoff.
+func contentionBackoff(base time.Duration, contestedCount int32) time.Duration {
+	backoff := base
+	for i := int32(1); i < contestedCount && backoff < maxContentionBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxContentionBackoff {
+		backoff = maxContentionBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,76 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var _ = Describe("Competing writers", func() {
+	DescribeTable("contentionBackoff doubles the base delay per contest up to maxContentionBackoff",
+		func(base time.Duration, contestedCount int32, expected time.Duration) {
+			Expect(contentionBackoff(base, contestedCount)).To(Equal(expected))
+		},
+		Entry("not contested before", time.Minute, int32(0), time.Minute),
+		Entry("contested once", time.Minute, int32(1), time.Minute),
+		Entry("contested twice", time.Minute, int32(2), 2*time.Minute),
+		Entry("contested four times", time.Minute, int32(4), 8*time.Minute),
+		Entry("capped", time.Minute, int32(10), maxContentionBackoff),
+		Entry("a base above the cap is capped", 2*time.Hour, int32(1), maxContentionBackoff),
+	)
+
+	DescribeTable("competingFieldManager finds other owners of the scaled field",
+		func(managedFields []metav1.ManagedFieldsEntry, expected string) {
+			Expect(competingFieldManager(managedFields, "spec", "replicas")).To(Equal(expected))
+		},
+		Entry("nobody else", []metav1.ManagedFieldsEntry{
+			{Manager: fieldManager, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)}},
+		}, ""),
+		Entry("another manager owning the field", []metav1.ManagedFieldsEntry{
+			{Manager: fieldManager, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)}},
+			{Manager: "argocd-controller", FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)}},
+		}, "argocd-controller"),
+		Entry("another manager owning other fields only", []metav1.ManagedFieldsEntry{
+			{Manager: "kubectl", FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{}}}`)}},
+		}, ""),
+		Entry("entries without fields", []metav1.ManagedFieldsEntry{
+			{Manager: "kubectl"},
+		}, ""),
+		Entry("unparsable fields", []metav1.ManagedFieldsEntry{
+			{Manager: "kubectl", FieldsV1: &metav1.FieldsV1{Raw: []byte(`not json`)}},
+		}, ""),
+	)
+
+	It("defaults the contention policy to Enforce with a one minute backoff", func() {
+		action, backoff := contentionPolicy(&autoscalingv1.ScheduledPodAutoscaler{})
+		Expect(action).To(Equal(autoscalingv1.ContentionActionEnforce))
+		Expect(backoff).To(Equal(defaultContentionBackoff))
+
+		action, backoff = contentionPolicy(&autoscalingv1.ScheduledPodAutoscaler{Spec: autoscalingv1.ScheduledPodAutoscalerSpec{
+			OnContention: &autoscalingv1.ContentionPolicy{Action: autoscalingv1.ContentionActionBackoff, BackoffDuration: &metav1.Duration{Duration: 5 * time.Minute}},
+		}})
+		Expect(action).To(Equal(autoscalingv1.ContentionActionBackoff))
+		Expect(backoff).To(Equal(5 * time.Minute))
+	})
+})
@@ -0,0 +1,337 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+const (
+	// deletionCostAnnotation is read by the ReplicaSet controller - pods with a lower cost are removed first:
+	deletionCostAnnotation = "controller.kubernetes.io/pod-deletion-cost"
+
+	// deletionCostOwnerAnnotation marks the pods whose deletion cost was set by an SPA - its value is the name of the SPA:
+	deletionCostOwnerAnnotation = "spa.sarmadabualkaz.io/deletion-cost"
+
+	// previousDeletionCostAnnotation keeps the deletion cost a pod had before an SPA set it - restored once the SPA
+	// removes its own:
+	previousDeletionCostAnnotation = "spa.sarmadabualkaz.io/previous-deletion-cost"
+
+	zoneLabel = "topology.kubernetes.io/zone"
+)
+
+var (
+	// deletionCostHold is how long the deletion costs are kept if the workload doesn't settle at the scaled-down replicas
+	// (e.g. an HPA only scaling down gradually after its minReplicas was lowered):
+	deletionCostHold = 10 * time.Minute
+
+	podGVK  = schema.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+	nodeGVK = schema.GroupVersionKind{Group: "", Kind: "Node", Version: "v1"}
+)
+
+// workloadPods returns the pods of a Deployment that aren't terminating yet - read uncached, like placeholder pods.
+func (r *ScheduledPodAutoscalerReconciler) workloadPods(ctx context.Context, workload *unstructured.Unstructured) ([]corev1.Pod, error) {
+	selectorMap, _, err := unstructured.NestedMap(workload.Object, "spec", "selector")
+	if err != nil {
+		return nil, err
+	}
+	labelSelector := &metav1.LabelSelector{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(selectorMap, labelSelector); err != nil {
+		return nil, err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(podListGVK)
+	if err := r.apiReader().List(ctx, list, client.InNamespace(workload.GetNamespace()), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+
+	pods := make([]corev1.Pod, 0, len(list.Items))
+	for _, item := range list.Items {
+		var pod corev1.Pod
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &pod); err != nil {
+			return nil, err
+		}
+		if pod.DeletionTimestamp == nil {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// podReady tells whether the pod's Ready condition is true.
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// podConnections returns the number of open connections a pod reports in the annotation - pods not reporting any count as none.
+func podConnections(pod *corev1.Pod, annotation string) int64 {
+	connections, err := strconv.ParseInt(pod.Annotations[annotation], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return connections
+}
+
+// deletionOrder sorts the pods in the order the strategy removes them - first to go first. zones maps node names to
+// their zone (ZoneBalance only) - pods not scheduled to a node yet go first with ZoneBalance, as they're in no zone
+// (and the ReplicaSet controller removes them before any scheduled pod anyway).
+func deletionOrder(pods []corev1.Pod, strategy *autoscalingv1.ScaleDownStrategy, zones map[string]string) []corev1.Pod {
+	ordered := make([]corev1.Pod, len(pods))
+	copy(ordered, pods)
+
+	// every strategy falls back to newest first - then by name, so the order is stable:
+	newer := func(i, j int) bool {
+		if !ordered[i].CreationTimestamp.Equal(&ordered[j].CreationTimestamp) {
+			return ordered[j].CreationTimestamp.Before(&ordered[i].CreationTimestamp)
+		}
+		return ordered[i].Name < ordered[j].Name
+	}
+
+	switch strategy.Type {
+	case autoscalingv1.ScaleDownUnreadyFirst:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			if podReady(&ordered[i]) != podReady(&ordered[j]) {
+				return !podReady(&ordered[i])
+			}
+			return newer(i, j)
+		})
+	case autoscalingv1.ScaleDownFewestConnections:
+		annotation := strategy.ConnectionsAnnotation
+		if annotation == "" {
+			annotation = autoscalingv1.DefaultConnectionsAnnotation
+		}
+		sort.SliceStable(ordered, func(i, j int) bool {
+			if ci, cj := podConnections(&ordered[i], annotation), podConnections(&ordered[j], annotation); ci != cj {
+				return ci < cj
+			}
+			return newer(i, j)
+		})
+	case autoscalingv1.ScaleDownZoneBalance:
+		sort.SliceStable(ordered, newer)
+
+		// take the next pod from the zone left with the most pods - the zone of its name on a tie:
+		byZone := map[string][]corev1.Pod{}
+		var zoneNames []string
+		var unscheduled []corev1.Pod
+		for _, pod := range ordered {
+			if pod.Spec.NodeName == "" {
+				unscheduled = append(unscheduled, pod)
+				continue
+			}
+			zone := zones[pod.Spec.NodeName]
+			if _, ok := byZone[zone]; !ok {
+				zoneNames = append(zoneNames, zone)
+			}
+			byZone[zone] = append(byZone[zone], pod)
+		}
+		sort.Strings(zoneNames)
+
+		ordered = append(ordered[:0], unscheduled...)
+		for len(ordered) < len(pods) {
+			fullest := zoneNames[0]
+			for _, zone := range zoneNames[1:] {
+				if len(byZone[zone]) > len(byZone[fullest]) {
+					fullest = zone
+				}
+			}
+			ordered = append(ordered, byZone[fullest][0])
+			byZone[fullest] = byZone[fullest][1:]
+		}
+	default:
+		sort.SliceStable(ordered, newer)
+	}
+	return ordered
+}
+
+// podZones returns the zone of every node running one of the pods - reading each node once, uncached like the nodes
+// of the pre-flight check (a cache would keep every node of the cluster).
+func (r *ScheduledPodAutoscalerReconciler) podZones(ctx context.Context, pods []corev1.Pod) (map[string]string, error) {
+	zones := map[string]string{}
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		if _, ok := zones[pod.Spec.NodeName]; ok {
+			continue
+		}
+
+		node := &unstructured.Unstructured{}
+		node.SetGroupVersionKind(nodeGVK)
+		if err := r.apiReader().Get(ctx, client.ObjectKey{Name: pod.Spec.NodeName}, node); err != nil {
+			return nil, err
+		}
+		zones[pod.Spec.NodeName] = node.GetLabels()[zoneLabel]
+	}
+	return zones, nil
+}
+
+// deploymentWorkload returns the Deployment creating the target's pods - nil if they aren't created by one
+// (only ReplicaSets honor the pod-deletion-cost).
+func (r *ScheduledPodAutoscalerReconciler) deploymentWorkload(ctx context.Context, target *scaleTarget) (*unstructured.Unstructured, error) {
+	workload, err := r.podWorkload(ctx, target)
+	if err != nil || workload == nil || workload.GroupVersionKind().GroupKind() != deploymentGVK.GroupKind() {
+		return nil, err
+	}
+	return workload, nil
+}
+
+// steeredDeletionCost returns the annotations of a pod with the deletion cost set by the named SPA - keeping the cost
+// the pod had before in previousDeletionCostAnnotation, unless it was set by an SPA too.
+func steeredDeletionCost(annotations map[string]string, spa string, cost string) map[string]string {
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	if _, steered := annotations[deletionCostOwnerAnnotation]; !steered {
+		if previous, ok := annotations[deletionCostAnnotation]; ok {
+			annotations[previousDeletionCostAnnotation] = previous
+		} else {
+			delete(annotations, previousDeletionCostAnnotation)
+		}
+	}
+
+	annotations[deletionCostAnnotation] = cost
+	annotations[deletionCostOwnerAnnotation] = spa
+	return annotations
+}
+
+// restoredDeletionCost returns the annotations of a pod with the deletion cost set by an SPA removed - and the cost
+// it had before restored.
+func restoredDeletionCost(annotations map[string]string) map[string]string {
+	if previous, ok := annotations[previousDeletionCostAnnotation]; ok {
+		annotations[deletionCostAnnotation] = previous
+	} else {
+		delete(annotations, deletionCostAnnotation)
+	}
+
+	delete(annotations, previousDeletionCostAnnotation)
+	delete(annotations, deletionCostOwnerAnnotation)
+	return annotations
+}
+
+// steerScaleDown sets the pod-deletion-cost of the target's pods in the order the strategy removes them - returning
+// the number of pods whose cost changed.
+func (r *ScheduledPodAutoscalerReconciler) steerScaleDown(ctx context.Context, spa *autoscalingv1.ScheduledPodAutoscaler, target *scaleTarget, status *autoscalingv1.TargetStatus, now time.Time) (int, error) {
+	workload, err := r.deploymentWorkload(ctx, target)
+	if err != nil || workload == nil {
+		return 0, err
+	}
+
+	pods, err := r.workloadPods(ctx, workload)
+	if err != nil {
+		return 0, err
+	}
+
+	var zones map[string]string
+	if spa.Spec.ScaleDownStrategy.Type == autoscalingv1.ScaleDownZoneBalance {
+		if zones, err = r.podZones(ctx, pods); err != nil {
+			return 0, err
+		}
+	}
+
+	changed := 0
+	for cost, pod := range deletionOrder(pods, spa.Spec.ScaleDownStrategy, zones) {
+		value := strconv.Itoa(cost)
+		if pod.Annotations[deletionCostAnnotation] == value && pod.Annotations[deletionCostOwnerAnnotation] == spa.Name {
+			continue
+		}
+
+		err := r.patchResource(ctx, podGVK, client.ObjectKey{Namespace: pod.Namespace, Name: pod.Name}, func(u *unstructured.Unstructured) error {
+			u.SetAnnotations(steeredDeletionCost(u.GetAnnotations(), spa.Name, value))
+			return nil
+		})
+		if client.IgnoreNotFound(err) != nil {
+			return changed, err
+		}
+		changed++
+	}
+
+	status.DeletionCostTime = &metav1.Time{Time: now}
+	return changed, nil
+}
+
+// clearDeletionCosts restores the pod-deletion-cost the pods had before the SPA set it (removing it if they had none)
+// once the workload settled at the scaled-down replicas (or deletionCostHold passed) - so later scale-downs by others
+// aren't steered by a stale order.
+func (r *ScheduledPodAutoscalerReconciler) clearDeletionCosts(ctx context.Context, spa *autoscalingv1.ScheduledPodAutoscaler, target *scaleTarget, status *autoscalingv1.TargetStatus, now time.Time) error {
+	if status.DeletionCostTime == nil {
+		return nil
+	}
+
+	workload, err := r.deploymentWorkload(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	if workload != nil && now.Before(status.DeletionCostTime.Add(deletionCostHold)) {
+		replicas, _, _ := unstructured.NestedInt64(workload.Object, "spec", "replicas")
+		statusReplicas, _, _ := unstructured.NestedInt64(workload.Object, "status", "replicas")
+		observedGeneration, _, _ := unstructured.NestedInt64(workload.Object, "status", "observedGeneration")
+
+		settled := status.LastAppliedReplicas != nil && replicas <= int64(*status.LastAppliedReplicas) &&
+			statusReplicas == replicas && observedGeneration >= workload.GetGeneration()
+		if !settled {
+			return nil
+		}
+	}
+
+	if workload != nil {
+		pods, err := r.workloadPods(ctx, workload)
+		if err != nil {
+			return err
+		}
+
+		for _, pod := range pods {
+			if pod.Annotations[deletionCostOwnerAnnotation] != spa.Name {
+				continue
+			}
+
+			err := r.patchResource(ctx, podGVK, client.ObjectKey{Namespace: pod.Namespace, Name: pod.Name}, func(u *unstructured.Unstructured) error {
+				u.SetAnnotations(restoredDeletionCost(u.GetAnnotations()))
+				return nil
+			})
+			if client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+
+	status.DeletionCostTime = nil
+	return nil
+}
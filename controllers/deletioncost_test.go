@@ -0,0 +1,133 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var _ = Describe("Scale-down strategies", func() {
+	created := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+
+	// pod returns a pod created minutes after 8:00 on the node - ready unless told otherwise:
+	type podOption func(*corev1.Pod)
+	pod := func(name string, minutes int, node string, options ...podOption) corev1.Pod {
+		p := corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.Time{Time: created.Add(time.Duration(minutes) * time.Minute)}},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status: corev1.PodStatus{Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+			}},
+		}
+		for _, option := range options {
+			option(&p)
+		}
+		return p
+	}
+	unready := func(p *corev1.Pod) { p.Status.Conditions[0].Status = corev1.ConditionFalse }
+	connections := func(count string) podOption {
+		return func(p *corev1.Pod) {
+			p.Annotations = map[string]string{autoscalingv1.DefaultConnectionsAnnotation: count}
+		}
+	}
+
+	zones := map[string]string{"node-a1": "a", "node-a2": "a", "node-b1": "b", "node-c1": "c", "node-unlabelled": ""}
+
+	DescribeTable("deletionOrder sorts pods in the order the strategy removes them",
+		func(strategyType string, pods []corev1.Pod, expected []string) {
+			ordered := deletionOrder(pods, &autoscalingv1.ScaleDownStrategy{Type: strategyType}, zones)
+
+			names := make([]string, 0, len(ordered))
+			for _, p := range ordered {
+				names = append(names, p.Name)
+			}
+			Expect(names).To(Equal(expected))
+		},
+		Entry("NewestFirst", autoscalingv1.ScaleDownNewestFirst, []corev1.Pod{
+			pod("old", 0, "node-a1"), pod("new", 20, "node-a1"), pod("middle", 10, "node-a1"),
+		}, []string{"new", "middle", "old"}),
+		Entry("NewestFirst - by name for pods of the same age", autoscalingv1.ScaleDownNewestFirst, []corev1.Pod{
+			pod("b", 0, "node-a1"), pod("a", 0, "node-a1"),
+		}, []string{"a", "b"}),
+		Entry("UnreadyFirst", autoscalingv1.ScaleDownUnreadyFirst, []corev1.Pod{
+			pod("ready-new", 20, "node-a1"), pod("unready-old", 0, "node-a1", unready), pod("unready-new", 10, "node-a1", unready),
+		}, []string{"unready-new", "unready-old", "ready-new"}),
+		Entry("FewestConnections", autoscalingv1.ScaleDownFewestConnections, []corev1.Pod{
+			pod("busy", 20, "node-a1", connections("50")), pod("idle", 0, "node-a1", connections("1")), pod("quiet", 10, "node-a1", connections("5")),
+		}, []string{"idle", "quiet", "busy"}),
+		Entry("FewestConnections - pods not reporting any count as none", autoscalingv1.ScaleDownFewestConnections, []corev1.Pod{
+			pod("busy", 20, "node-a1", connections("50")), pod("silent", 0, "node-a1"), pod("garbled", 10, "node-a1", connections("many")),
+		}, []string{"garbled", "silent", "busy"}),
+		Entry("ZoneBalance - from the zone with the most pods", autoscalingv1.ScaleDownZoneBalance, []corev1.Pod{
+			pod("a-old", 0, "node-a1"), pod("a-new", 20, "node-a2"), pod("a-middle", 10, "node-a1"), pod("b", 30, "node-b1"),
+		}, []string{"a-new", "a-middle", "a-old", "b"}),
+		Entry("ZoneBalance - the zone of its name first on a tie", autoscalingv1.ScaleDownZoneBalance, []corev1.Pod{
+			pod("c", 30, "node-c1"), pod("b", 20, "node-b1"), pod("a", 0, "node-a1"),
+		}, []string{"a", "b", "c"}),
+		Entry("ZoneBalance - ties broken again as zones even out", autoscalingv1.ScaleDownZoneBalance, []corev1.Pod{
+			pod("a-old", 0, "node-a1"), pod("a-new", 10, "node-a2"), pod("b-old", 0, "node-b1"), pod("b-new", 10, "node-b1"),
+		}, []string{"a-new", "b-new", "a-old", "b-old"}),
+		Entry("ZoneBalance - pods without a node go first", autoscalingv1.ScaleDownZoneBalance, []corev1.Pod{
+			pod("a-new", 20, "node-a1"), pod("a-old", 0, "node-a1"), pod("pending-old", 0, ""), pod("pending-new", 10, ""),
+		}, []string{"pending-new", "pending-old", "a-new", "a-old"}),
+		Entry("ZoneBalance - nodes without a zone are a zone of their own (sorting first)", autoscalingv1.ScaleDownZoneBalance, []corev1.Pod{
+			pod("a", 0, "node-a1"), pod("unlabelled-old", 0, "node-unlabelled"), pod("unlabelled-new", 10, "node-unlabelled"),
+		}, []string{"unlabelled-new", "unlabelled-old", "a"}),
+	)
+
+	DescribeTable("steeredDeletionCost keeps the cost a pod had before",
+		func(annotations map[string]string, expected map[string]string) {
+			Expect(steeredDeletionCost(annotations, "spa", "3")).To(Equal(expected))
+		},
+		Entry("a pod without annotations", nil, map[string]string{
+			deletionCostAnnotation: "3", deletionCostOwnerAnnotation: "spa",
+		}),
+		Entry("a pod with a cost of its own", map[string]string{deletionCostAnnotation: "100", "other": "kept"}, map[string]string{
+			deletionCostAnnotation: "3", deletionCostOwnerAnnotation: "spa", previousDeletionCostAnnotation: "100", "other": "kept",
+		}),
+		Entry("a pod steered before", map[string]string{
+			deletionCostAnnotation: "1", deletionCostOwnerAnnotation: "spa", previousDeletionCostAnnotation: "100",
+		}, map[string]string{
+			deletionCostAnnotation: "3", deletionCostOwnerAnnotation: "spa", previousDeletionCostAnnotation: "100",
+		}),
+		Entry("a pod steered by another SPA", map[string]string{
+			deletionCostAnnotation: "1", deletionCostOwnerAnnotation: "other",
+		}, map[string]string{
+			deletionCostAnnotation: "3", deletionCostOwnerAnnotation: "spa",
+		}),
+	)
+
+	DescribeTable("restoredDeletionCost restores the cost a pod had before",
+		func(annotations map[string]string, expected map[string]string) {
+			Expect(restoredDeletionCost(annotations)).To(Equal(expected))
+		},
+		Entry("a pod without a cost of its own", map[string]string{
+			deletionCostAnnotation: "3", deletionCostOwnerAnnotation: "spa", "other": "kept",
+		}, map[string]string{"other": "kept"}),
+		Entry("a pod with a cost of its own", map[string]string{
+			deletionCostAnnotation: "3", deletionCostOwnerAnnotation: "spa", previousDeletionCostAnnotation: "100",
+		}, map[string]string{deletionCostAnnotation: "100"}),
+	)
+})
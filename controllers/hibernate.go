@@ -0,0 +1,153 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var (
+	// originalReplicasAnnotation holds the replicas a workload had before its namespace went to sleep:
+	originalReplicasAnnotation = "spa.sarmadabualkaz.io/original-replicas"
+
+	// hibernatedKinds are the workloads scaled to zero by a Hibernate mode SPA:
+	hibernatedKinds = []schema.GroupVersionKind{deploymentGVK, statefulSetGVK}
+)
+
+// hibernateNamespace scales the Deployments and StatefulSets of the namespace to zero (asleep) or back to the
+// replicas they had before (awake) - it returns the workloads it changed, e.g. "deployment test-deployment".
+// Workloads scaled by another SPA (including SPAs of a ClusterScheduledPodAutoscaler) or by a ScheduledScalingGroup
+// are left alone - the SPA is named by spa.
+//
+// Replicas are written through /scale, the original replicas are kept in an annotation: it is written before a
+// workload is scaled to zero and only removed once its replicas are restored - so a workload is never at zero
+// without knowing its original replicas. Workloads created while the namespace is asleep are put to sleep on the
+// next reconcile.
+func (r *ScheduledPodAutoscalerReconciler) hibernateNamespace(ctx context.Context, namespace string, spa string, asleep bool) ([]string, error) {
+	var changed []string
+
+	claims, err := r.scaleClaims(ctx, namespace, spa)
+	if err != nil {
+		return changed, err
+	}
+
+	for _, gvk := range hibernatedKinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+
+		if err := r.List(ctx, list, client.InNamespace(namespace)); err != nil {
+			return changed, err
+		}
+
+		for i := range list.Items {
+			workload := &list.Items[i]
+			if len(claims[gvk.Kind+"/"+workload.GetName()]) > 0 {
+				continue
+			}
+
+			target, err := r.newScaleTarget(strings.ToLower(gvk.Kind), autoscalingv1.Resource{Name: workload.GetName()}, client.ObjectKeyFromObject(workload))
+			if err != nil {
+				return changed, err
+			}
+
+			var scaled bool
+			if asleep {
+				scaled, err = r.putToSleep(ctx, target, workload)
+			} else {
+				scaled, err = r.wakeUp(ctx, target, workload)
+			}
+			if err != nil {
+				return changed, err
+			} else if !scaled {
+				continue
+			}
+			changed = append(changed, fmt.Sprintf("%s %s", strings.ToLower(gvk.Kind), workload.GetName()))
+		}
+	}
+	return changed, nil
+}
+
+// putToSleep scales a workload to zero - recording its replicas first unless they were already recorded
+// (i.e. it was scaled up again while asleep). It returns whether the workload was scaled.
+func (r *ScheduledPodAutoscalerReconciler) putToSleep(ctx context.Context, target *scaleTarget, workload *unstructured.Unstructured) (bool, error) {
+	if workloadReplicas(workload) == 0 {
+		return false, nil
+	}
+
+	if _, ok := workload.GetAnnotations()[originalReplicasAnnotation]; !ok {
+		err := r.patchResource(ctx, target.gvk, target.key, func(u *unstructured.Unstructured) error {
+			if _, ok := u.GetAnnotations()[originalReplicasAnnotation]; ok {
+				return nil
+			}
+			return setReplicaField(u, []string{"metadata", "annotations", originalReplicasAnnotation}, workloadReplicas(u))
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, r.updateScale(ctx, target, 0)
+}
+
+// wakeUp restores the recorded replicas of a workload - and forgets them once they are restored. It returns
+// whether the workload was scaled.
+func (r *ScheduledPodAutoscalerReconciler) wakeUp(ctx context.Context, target *scaleTarget, workload *unstructured.Unstructured) (bool, error) {
+	replicas, ok, err := originalReplicas(workload)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if err := r.updateScale(ctx, target, replicas); err != nil {
+		return false, err
+	}
+
+	return true, r.patchResource(ctx, target.gvk, target.key, func(u *unstructured.Unstructured) error {
+		unstructured.RemoveNestedField(u.Object, "metadata", "annotations", originalReplicasAnnotation)
+		return nil
+	})
+}
+
+// originalReplicas returns the replicas recorded in the annotation of a hibernated workload - false if there are none.
+func originalReplicas(u *unstructured.Unstructured) (int32, bool, error) {
+	original, ok := u.GetAnnotations()[originalReplicasAnnotation]
+	if !ok {
+		return 0, false, nil
+	}
+
+	replicas, err := strconv.ParseInt(original, 10, 32)
+	if err != nil {
+		return 0, false, fmt.Errorf("unable to restore replicas of %s from annotation %s=%q: %w", u.GetName(), originalReplicasAnnotation, original, err)
+	}
+	return int32(replicas), true, nil
+}
+
+// workloadReplicas returns spec.replicas of a Deployment or StatefulSet - which defaults to 1 when unset.
+func workloadReplicas(u *unstructured.Unstructured) int32 {
+	replicas, found, err := unstructured.NestedInt64(u.Object, "spec", "replicas")
+	if err != nil || !found {
+		return 1
+	}
+	return int32(replicas)
+}
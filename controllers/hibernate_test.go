@@ -0,0 +1,113 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var _ = Describe("Hibernate mode", func() {
+	ctx := context.Background()
+	namespace := "hibernate-test"
+
+	// setWindow moves the schedule of the SPA so its namespace is asleep (scaleDown active) or awake (scaleUp active):
+	setWindow := func(spa *autoscalingv1.ScheduledPodAutoscaler, asleep bool) {
+		now := time.Now().UTC()
+		past, future := now.Add(-time.Hour).Format(time.Kitchen), now.Add(time.Hour).Format(time.Kitchen)
+
+		spa.Spec.ScaleUp.Time, spa.Spec.ScaleDown.Time = past, future
+		if asleep {
+			spa.Spec.ScaleUp.Time, spa.Spec.ScaleDown.Time = future, past
+		}
+	}
+
+	reconcile := func(reconciler *ScheduledPodAutoscalerReconciler, spa *autoscalingv1.ScheduledPodAutoscaler) {
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: spa.Name, Namespace: spa.Namespace}})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	getDeployment := func(name string) *unstructured.Unstructured {
+		deployment := &unstructured.Unstructured{}
+		deployment.SetGroupVersionKind(deploymentGVK)
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, deployment)).To(Succeed())
+		return deployment
+	}
+
+	It("scales unclaimed workloads to zero, including ones created while asleep, and restores them on waking up", func() {
+		reconciler := newTestReconciler()
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+
+		Expect(k8sClient.Create(ctx, newTestDeployment("app", namespace, 3))).To(Succeed())
+		Expect(k8sClient.Create(ctx, newTestDeployment("scheduled", namespace, 2))).To(Succeed())
+
+		// another SPA scales the "scheduled" deployment:
+		other := newTestSPA("scheduled", autoscalingv1.Resource{Name: "scheduled", Type: "deployment"})
+		other.Namespace = namespace
+		Expect(k8sClient.Create(ctx, other)).To(Succeed())
+
+		spa := &autoscalingv1.ScheduledPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "hibernate", Namespace: namespace},
+			Spec: autoscalingv1.ScheduledPodAutoscalerSpec{
+				Mode:             autoscalingv1.ModeHibernate,
+				ScheduleCalendar: autoscalingv1.ScheduleCalendar{TimeZone: "UTC"},
+			},
+		}
+		setWindow(spa, true)
+		Expect(k8sClient.Create(ctx, spa)).To(Succeed())
+
+		By("putting the namespace to sleep")
+		reconcile(reconciler, spa)
+
+		app := getDeployment("app")
+		Expect(workloadReplicas(app)).To(Equal(int32(0)))
+		Expect(app.GetAnnotations()).To(HaveKeyWithValue(originalReplicasAnnotation, "3"))
+		Expect(workloadReplicas(getDeployment("scheduled"))).To(Equal(int32(2)))
+		Expect(getDeployment("scheduled").GetAnnotations()).NotTo(HaveKey(originalReplicasAnnotation))
+
+		By("putting a workload created while asleep to sleep too")
+		Expect(k8sClient.Create(ctx, newTestDeployment("late", namespace, 2))).To(Succeed())
+		reconcile(reconciler, spa)
+
+		late := getDeployment("late")
+		Expect(workloadReplicas(late)).To(Equal(int32(0)))
+		Expect(late.GetAnnotations()).To(HaveKeyWithValue(originalReplicasAnnotation, "2"))
+		Expect(getDeployment("app").GetAnnotations()).To(HaveKeyWithValue(originalReplicasAnnotation, "3"))
+
+		By("waking the namespace up")
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: spa.Name, Namespace: namespace}, spa)).To(Succeed())
+		setWindow(spa, false)
+		Expect(k8sClient.Update(ctx, spa)).To(Succeed())
+		reconcile(reconciler, spa)
+
+		for name, replicas := range map[string]int32{"app": 3, "late": 2, "scheduled": 2} {
+			deployment := getDeployment(name)
+			Expect(workloadReplicas(deployment)).To(Equal(replicas), name)
+			Expect(deployment.GetAnnotations()).NotTo(HaveKey(originalReplicasAnnotation), name)
+		}
+	})
+})
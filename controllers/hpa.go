@@ -0,0 +1,210 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+// hpaProfileFields are the HPA spec fields replaced by an hpaProfile:
+var hpaProfileFields = []string{"metrics", "behavior"}
+
+// getHPAProfile reads the metrics and behavior of an autoscaling/v2 HPA.
+func getHPAProfile(u *unstructured.Unstructured) (*autoscalingv1.HPAProfile, error) {
+	spec, _, err := unstructured.NestedMap(u.Object, "spec")
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &autoscalingv1.HPAProfile{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// setFields returns the hpaProfileFields a profile sets - none for a nil profile.
+func setFields(profile *autoscalingv1.HPAProfile) map[string]bool {
+	fields := map[string]bool{}
+	if profile != nil && len(profile.Metrics) > 0 {
+		fields["metrics"] = true
+	}
+	if profile != nil && profile.Behavior != nil {
+		fields["behavior"] = true
+	}
+	return fields
+}
+
+// hpaProfileContent returns the fields of a profile as they are written to the HPA spec - none for a nil profile.
+func hpaProfileContent(profile *autoscalingv1.HPAProfile) (map[string]interface{}, error) {
+	if profile == nil {
+		return map[string]interface{}{}, nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(profile)
+}
+
+// setHPAProfile writes the given fields of an autoscaling/v2 HPA - from the profile if it sets them, otherwise from
+// original (removing fields original doesn't hold). Fields not given are left as they are.
+func setHPAProfile(u *unstructured.Unstructured, profile *autoscalingv1.HPAProfile, original *autoscalingv1.HPAProfile, fields map[string]bool) error {
+	profileContent, err := hpaProfileContent(profile)
+	if err != nil {
+		return err
+	}
+	originalContent, err := hpaProfileContent(original)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range hpaProfileFields {
+		if !fields[field] {
+			continue
+		}
+
+		content := originalContent
+		if setFields(profile)[field] {
+			content = profileContent
+		}
+
+		if value, ok := content[field]; ok {
+			if err := unstructured.SetNestedField(u.Object, value, "spec", field); err != nil {
+				return err
+			}
+		} else {
+			unstructured.RemoveNestedField(u.Object, "spec", field)
+		}
+	}
+	return nil
+}
+
+// applyHPAProfile replaces the fields the profile sets with the profile's - and the fields only the previously applied
+// profile set with the original ones recorded in status (all of them if profile is nil). The original metrics and behavior
+// are recorded before the first profile is applied, and forgotten once restored. It tells whether the HPA was patched.
+func (r *ScheduledPodAutoscalerReconciler) applyHPAProfile(ctx context.Context, target *scaleTarget, profile *autoscalingv1.HPAProfile, previous *autoscalingv1.HPAProfile, status *autoscalingv1.TargetStatus) (bool, error) {
+	if status.ActiveHPAProfile == "" {
+		original, err := getHPAProfile(target.object)
+		if err != nil {
+			return false, err
+		}
+		status.OriginalHPAProfile = original
+	}
+
+	fields := setFields(profile)
+	if status.OriginalHPAProfile != nil {
+		for field := range setFields(previous) {
+			fields[field] = true
+		}
+	}
+
+	patched := false
+	if len(fields) > 0 {
+		err := r.patchResource(ctx, target.gvk, target.key, func(u *unstructured.Unstructured) error {
+			return setHPAProfile(u, profile, status.OriginalHPAProfile, fields)
+		})
+		if err != nil {
+			return false, err
+		}
+		patched = true
+	}
+
+	if profile == nil {
+		status.OriginalHPAProfile = nil
+	}
+	return patched, nil
+}
+
+// autoscalerMaxReplicas returns the maxReplicas of an autoscaler target for the active step: the step's (or its profile's)
+// maxReplicas if set, otherwise the original maxReplicas once the scale-down window begins (restores is true then),
+// otherwise the current one - but never below minReplicas. nil (an unset maxReplicas) is left to the autoscaler's own default.
+func autoscalerMaxReplicas(current *int32, stepMax *int32, original *int32, scaleDown bool, minReplicas int32) (maxReplicas *int32, restores bool) {
+	maxReplicas = current
+	if stepMax != nil {
+		maxReplicas = stepMax
+	} else if scaleDown && original != nil {
+		maxReplicas, restores = original, true
+	}
+
+	if maxReplicas != nil && *maxReplicas < minReplicas {
+		maxReplicas = &minReplicas
+	}
+	return maxReplicas, restores
+}
+
+// hpaScaleTarget returns the resource scaled by the HPA of an hpa or hpaOperator target - the Deployment itself for the HPA-operator.
+func (r *ScheduledPodAutoscalerReconciler) hpaScaleTarget(ctx context.Context, target *scaleTarget) (*scaleTarget, error) {
+	if target.resourceType == "hpaOperator" {
+		return r.getScaleTarget(ctx, "deployment", autoscalingv1.Resource{Name: target.key.Name}, target.key.Namespace)
+	}
+
+	ref, _, err := unstructured.NestedStringMap(target.object.Object, "spec", "scaleTargetRef")
+	if err != nil {
+		return nil, err
+	}
+	return r.getScaleTarget(ctx, "scale", autoscalingv1.Resource{Name: ref["name"], APIVersion: ref["apiVersion"], Kind: ref["kind"]}, target.key.Namespace)
+}
+
+// zeroHPAScaleTarget scales the resource scaled by the HPA to zero - which disables the HPA until it is scaled up
+// again - recording its replicas in status. When zero is false, the recorded replicas (but at least minReplicas) are
+// restored and forgotten. It returns the resource scaled by the HPA and whether its replicas were changed.
+func (r *ScheduledPodAutoscalerReconciler) zeroHPAScaleTarget(ctx context.Context, target *scaleTarget, zero bool, minReplicas int32, status *autoscalingv1.TargetStatus) (*scaleTarget, bool, error) {
+	scaled, err := r.hpaScaleTarget(ctx, target)
+	if err != nil {
+		return nil, false, err
+	}
+
+	current := scaled.replicas()
+	if current == nil {
+		return scaled, false, fmt.Errorf("unable to read replicas of %s", scaled)
+	}
+
+	replicas, changed, original := pausedReplicas(zero, *current, status.OriginalTargetReplicas, minReplicas)
+	if changed {
+		if err := r.updateScale(ctx, scaled, replicas); err != nil {
+			return scaled, false, err
+		}
+	}
+	status.OriginalTargetReplicas = original
+	return scaled, changed, nil
+}
+
+// pausedReplicas returns the replicas of the resource scaled by an HPA while the HPA is paused (zero is true) or after
+// - and whether it needs to be scaled to them. While paused that is zero, remembering the current replicas unless
+// original already holds them. After, it is the original replicas (but at least minReplicas) if the resource is still
+// at zero - and the original replicas are forgotten.
+func pausedReplicas(zero bool, current int32, original *int32, minReplicas int32) (replicas int32, scale bool, remembered *int32) {
+	if zero {
+		if original == nil && current != 0 {
+			original = &current
+		}
+		return 0, current != 0, original
+	}
+
+	if original == nil {
+		return current, false, nil
+	}
+
+	replicas = *original
+	if replicas < minReplicas {
+		replicas = minReplicas
+	}
+	return replicas, current == 0, nil
+}
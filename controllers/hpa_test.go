@@ -0,0 +1,128 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var _ = Describe("HPA targets", func() {
+	int32Ptr := func(i int32) *int32 { return &i }
+
+	DescribeTable("autoscalerMaxReplicas works out maxReplicas for the active step",
+		func(current *int32, stepMax *int32, original *int32, scaleDown bool, minReplicas int32, expected *int32, expectedRestores bool) {
+			maxReplicas, restores := autoscalerMaxReplicas(current, stepMax, original, scaleDown, minReplicas)
+			Expect(maxReplicas).To(Equal(expected))
+			Expect(restores).To(Equal(expectedRestores))
+		},
+		Entry("keeps the current maxReplicas", int32Ptr(10), nil, nil, false, int32(4), int32Ptr(10), false),
+		Entry("the step's maxReplicas", int32Ptr(10), int32Ptr(20), nil, false, int32(4), int32Ptr(20), false),
+		Entry("the step's maxReplicas over the original at scale-down", int32Ptr(20), int32Ptr(8), int32Ptr(10), true, int32(2), int32Ptr(8), false),
+		Entry("restores the original at scale-down", int32Ptr(20), nil, int32Ptr(10), true, int32(2), int32Ptr(10), true),
+		Entry("keeps the current maxReplicas at scale-up even with an original", int32Ptr(20), nil, int32Ptr(10), false, int32(4), int32Ptr(20), false),
+		Entry("never below minReplicas", int32Ptr(10), int32Ptr(3), nil, false, int32(4), int32Ptr(4), false),
+		Entry("a restored original never below minReplicas", int32Ptr(20), nil, int32Ptr(3), true, int32(4), int32Ptr(4), true),
+		Entry("an unset maxReplicas is left unset", nil, nil, nil, false, int32(4), nil, false),
+	)
+
+	DescribeTable("pausedReplicas pauses an HPA at zero and restores its scale target after",
+		func(zero bool, current int32, original *int32, minReplicas int32, expected int32, expectedScale bool, expectedOriginal *int32) {
+			replicas, scale, remembered := pausedReplicas(zero, current, original, minReplicas)
+			Expect(replicas).To(Equal(expected))
+			Expect(scale).To(Equal(expectedScale))
+			Expect(remembered).To(Equal(expectedOriginal))
+		},
+		Entry("scales to zero remembering the replicas", true, int32(6), nil, int32(1), int32(0), true, int32Ptr(6)),
+		Entry("keeps the first remembered replicas when scaled up while paused", true, int32(2), int32Ptr(6), int32(1), int32(0), true, int32Ptr(6)),
+		Entry("leaves a resource already at zero", true, int32(0), int32Ptr(6), int32(1), int32(0), false, int32Ptr(6)),
+		Entry("remembers nothing for a resource that was at zero anyway", true, int32(0), nil, int32(1), int32(0), false, nil),
+		Entry("restores the remembered replicas", false, int32(0), int32Ptr(6), int32(2), int32(6), true, nil),
+		Entry("restores at least minReplicas", false, int32(0), int32Ptr(2), int32(4), int32(4), true, nil),
+		Entry("forgets the replicas of a resource scaled up by someone else", false, int32(3), int32Ptr(6), int32(2), int32(6), false, nil),
+		Entry("has nothing to restore without remembered replicas", false, int32(3), nil, int32(2), int32(3), false, nil),
+	)
+
+	Context("with hpaProfiles", func() {
+		window := int32(600)
+		utilization := int32(70)
+
+		memoryMetrics := []autoscalingv2beta2.MetricSpec{{
+			Type: autoscalingv2beta2.ResourceMetricSourceType,
+			Resource: &autoscalingv2beta2.ResourceMetricSource{
+				Name:   "memory",
+				Target: autoscalingv2beta2.MetricTarget{Type: autoscalingv2beta2.UtilizationMetricType, AverageUtilization: &utilization},
+			},
+		}}
+		slowScaleDown := &autoscalingv2beta2.HorizontalPodAutoscalerBehavior{
+			ScaleDown: &autoscalingv2beta2.HPAScalingRules{StabilizationWindowSeconds: &window},
+		}
+
+		newHPA := func() *unstructured.Unstructured {
+			return &unstructured.Unstructured{Object: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"metrics": []interface{}{map[string]interface{}{"type": "Pods"}},
+					"behavior": map[string]interface{}{
+						"scaleDown": map[string]interface{}{"stabilizationWindowSeconds": int64(300)},
+					},
+				},
+			}}
+		}
+
+		It("only replaces the fields the profile sets", func() {
+			hpa := newHPA()
+			profile := &autoscalingv1.HPAProfile{Metrics: memoryMetrics}
+
+			Expect(setHPAProfile(hpa, profile, nil, setFields(profile))).To(Succeed())
+
+			metrics, _, _ := unstructured.NestedSlice(hpa.Object, "spec", "metrics")
+			Expect(metrics).To(HaveLen(1))
+			Expect(metrics[0]).To(HaveKeyWithValue("type", "Resource"))
+			window, _, _ := unstructured.NestedInt64(hpa.Object, "spec", "behavior", "scaleDown", "stabilizationWindowSeconds")
+			Expect(window).To(Equal(int64(300)))
+		})
+
+		It("restores the original of fields the previous profile set - removing those the HPA didn't have", func() {
+			hpa := newHPA()
+			unstructured.RemoveNestedField(hpa.Object, "spec", "behavior")
+			original, err := getHPAProfile(hpa)
+			Expect(err).NotTo(HaveOccurred())
+
+			previous := &autoscalingv1.HPAProfile{Metrics: memoryMetrics, Behavior: slowScaleDown}
+			Expect(setHPAProfile(hpa, previous, original, setFields(previous))).To(Succeed())
+			_, found, _ := unstructured.NestedMap(hpa.Object, "spec", "behavior")
+			Expect(found).To(BeTrue())
+
+			Expect(setHPAProfile(hpa, nil, original, setFields(previous))).To(Succeed())
+
+			metrics, _, _ := unstructured.NestedSlice(hpa.Object, "spec", "metrics")
+			Expect(metrics).To(Equal([]interface{}{map[string]interface{}{"type": "Pods"}}))
+			_, found, _ = unstructured.NestedMap(hpa.Object, "spec", "behavior")
+			Expect(found).To(BeFalse())
+		})
+
+		It("sets no fields for a nil profile", func() {
+			Expect(setFields(nil)).To(BeEmpty())
+			Expect(setFields(&autoscalingv1.HPAProfile{Behavior: slowScaleDown})).To(Equal(map[string]bool{"behavior": true}))
+		})
+	})
+})
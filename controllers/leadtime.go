@@ -0,0 +1,92 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var (
+	// leadTimeMargin is added to the longest observed time to ready with leadTime auto:
+	leadTimeMargin = 2 * time.Minute
+
+	// maxAutoLeadTime caps the lead time worked out with leadTime auto:
+	maxAutoLeadTime = time.Hour
+
+	// keptTimesToReady is how many of the latest times to ready are kept per target:
+	keptTimesToReady = 5
+)
+
+// recordTimeToReady keeps how long a scale-up of the target took until its replicas were ready - along with the
+// latest ones before it.
+func recordTimeToReady(status *autoscalingv1.TargetStatus, elapsed time.Duration) {
+	status.TimesToReady = append(status.TimesToReady, metav1.Duration{Duration: elapsed.Round(time.Second)})
+	if len(status.TimesToReady) > keptTimesToReady {
+		status.TimesToReady = status.TimesToReady[len(status.TimesToReady)-keptTimesToReady:]
+	}
+}
+
+// leadTime works out how long before scaleUp.time the scale-up starts: the duration of spec.leadTime - or with auto,
+// the longest time to ready observed recently on any target plus leadTimeMargin (0 until one is observed).
+func leadTime(spa *autoscalingv1.ScheduledPodAutoscaler) (time.Duration, error) {
+	switch spa.Spec.LeadTime {
+	case "":
+		return 0, nil
+	case autoscalingv1.LeadTimeAuto:
+	default:
+		return time.ParseDuration(spa.Spec.LeadTime)
+	}
+
+	statuses := []autoscalingv1.TargetStatus{spa.Status.TargetStatus}
+	for _, entry := range spa.Status.Targets {
+		statuses = append(statuses, entry.TargetStatus)
+	}
+
+	var longest time.Duration
+	for _, status := range statuses {
+		for _, observed := range status.TimesToReady {
+			if observed.Duration > longest {
+				longest = observed.Duration
+			}
+		}
+	}
+
+	if longest == 0 {
+		return 0, nil
+	}
+	if longest+leadTimeMargin > maxAutoLeadTime {
+		return maxAutoLeadTime, nil
+	}
+	return longest + leadTimeMargin, nil
+}
+
+// cappedLeadTime keeps the lead time from reaching back to scaleDown - at most half the time between the scaleDown and
+// the scaleUp clock times is taken.
+func cappedLeadTime(lead time.Duration, scaleUpClock time.Time, scaleDownClock time.Time) time.Duration {
+	scaleDownWindow := scaleUpClock.Sub(scaleDownClock)
+	if scaleDownWindow <= 0 {
+		scaleDownWindow += 24 * time.Hour
+	}
+	if lead > scaleDownWindow/2 {
+		return scaleDownWindow / 2
+	}
+	return lead
+}
@@ -0,0 +1,96 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var _ = Describe("Lead time", func() {
+	observed := func(durations ...time.Duration) autoscalingv1.TargetStatus {
+		status := autoscalingv1.TargetStatus{}
+		for _, duration := range durations {
+			status.TimesToReady = append(status.TimesToReady, metav1.Duration{Duration: duration})
+		}
+		return status
+	}
+
+	DescribeTable("leadTime works out the lead time of an SPA",
+		func(leadTimeSpec string, status autoscalingv1.ScheduledPodAutoscalerStatus, expected time.Duration) {
+			spa := &autoscalingv1.ScheduledPodAutoscaler{
+				Spec:   autoscalingv1.ScheduledPodAutoscalerSpec{LeadTime: leadTimeSpec},
+				Status: status,
+			}
+			lead, err := leadTime(spa)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lead).To(Equal(expected))
+		},
+		Entry("no lead time", "", autoscalingv1.ScheduledPodAutoscalerStatus{TargetStatus: observed(5 * time.Minute)}, time.Duration(0)),
+		Entry("a fixed lead time", "10m", autoscalingv1.ScheduledPodAutoscalerStatus{TargetStatus: observed(5 * time.Minute)}, 10*time.Minute),
+		Entry("auto before any time to ready is observed", autoscalingv1.LeadTimeAuto, autoscalingv1.ScheduledPodAutoscalerStatus{}, time.Duration(0)),
+		Entry("auto - the longest time to ready plus the margin", autoscalingv1.LeadTimeAuto,
+			autoscalingv1.ScheduledPodAutoscalerStatus{TargetStatus: observed(3*time.Minute, 7*time.Minute, 5*time.Minute)}, 9*time.Minute),
+		Entry("auto - the longest time to ready of any target", autoscalingv1.LeadTimeAuto,
+			autoscalingv1.ScheduledPodAutoscalerStatus{Targets: []autoscalingv1.NamedTargetStatus{
+				{Name: "web", TargetStatus: observed(4 * time.Minute)},
+				{Name: "api", TargetStatus: observed(12 * time.Minute)},
+			}}, 14*time.Minute),
+		Entry("auto - at most maxAutoLeadTime", autoscalingv1.LeadTimeAuto,
+			autoscalingv1.ScheduledPodAutoscalerStatus{TargetStatus: observed(59 * time.Minute)}, maxAutoLeadTime),
+	)
+
+	It("rejects a lead time that is no duration", func() {
+		_, err := leadTime(&autoscalingv1.ScheduledPodAutoscaler{Spec: autoscalingv1.ScheduledPodAutoscalerSpec{LeadTime: "soon"}})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("keeps the latest times to ready", func() {
+		status := &autoscalingv1.TargetStatus{}
+		for i := 1; i <= keptTimesToReady+2; i++ {
+			recordTimeToReady(status, time.Duration(i)*time.Minute+400*time.Millisecond)
+		}
+
+		Expect(status.TimesToReady).To(HaveLen(keptTimesToReady))
+		Expect(status.TimesToReady[0].Duration).To(Equal(3 * time.Minute))
+		Expect(status.TimesToReady[keptTimesToReady-1].Duration).To(Equal(time.Duration(keptTimesToReady+2) * time.Minute))
+	})
+
+	DescribeTable("cappedLeadTime keeps the lead time from reaching back to scaleDown",
+		func(lead time.Duration, scaleUpTime string, scaleDownTime string, expected time.Duration) {
+			scaleUpClock, err := time.Parse(time.Kitchen, scaleUpTime)
+			Expect(err).NotTo(HaveOccurred())
+			scaleDownClock, err := time.Parse(time.Kitchen, scaleDownTime)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(cappedLeadTime(lead, scaleUpClock, scaleDownClock)).To(Equal(expected))
+		},
+		Entry("a lead time within the scaleDown window", 30*time.Minute, "8:00AM", "7:00PM", 30*time.Minute),
+		Entry("a lead time just below half the scaleDown window", 6*time.Hour+29*time.Minute, "8:00AM", "7:00PM", 6*time.Hour+29*time.Minute),
+		Entry("a lead time of half the scaleDown window", 6*time.Hour+30*time.Minute, "8:00AM", "7:00PM", 6*time.Hour+30*time.Minute),
+		Entry("a lead time just above half the scaleDown window", 6*time.Hour+31*time.Minute, "8:00AM", "7:00PM", 6*time.Hour+30*time.Minute),
+		Entry("a lead time longer than the scaleDown window", 14*time.Hour, "8:00AM", "7:00PM", 6*time.Hour+30*time.Minute),
+		Entry("a night shift", 2*time.Hour, "10:00PM", "9:00PM", 30*time.Minute),
+		Entry("a night shift with room for the lead time", 2*time.Hour, "10:00PM", "6:00AM", 2*time.Hour),
+	)
+})
@@ -0,0 +1,119 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+// linkIndex indexes SPAs by the workloads their scale steps follow - as <kind>/<name>:
+var linkIndex = "spec.link"
+
+// linkKeys returns the linkIndex values of an SPA - one per linked scale step.
+func linkKeys(spa *autoscalingv1.ScheduledPodAutoscaler) []string {
+	var keys []string
+	for _, step := range []autoscalingv1.ScaleSpec{spa.Spec.ScaleUp, spa.Spec.ScaleDown} {
+		if step.Link != nil {
+			keys = append(keys, linkKind(step.Link)+"/"+step.Link.Name)
+		}
+	}
+	return keys
+}
+
+// scaleStepSet checks whether a scale step sets what to scale to - a value, a profile or a link.
+func scaleStepSet(step autoscalingv1.ScaleSpec) bool {
+	return step.Value != nil || step.Profile != "" || step.Link != nil
+}
+
+// linkKind returns the kind of the linked workload - Deployment if unset.
+func linkKind(link *autoscalingv1.ReplicaLink) string {
+	if link.Kind == "" {
+		return deploymentGVK.Kind
+	}
+	return link.Kind
+}
+
+// linkedReplicas fetches the workload a scale step follows and works out the step's value from its status.replicas.
+func (r *ScheduledPodAutoscalerReconciler) linkedReplicas(ctx context.Context, namespace string, link *autoscalingv1.ReplicaLink) (int32, error) {
+	gvk := deploymentGVK
+	if linkKind(link) == statefulSetGVK.Kind {
+		gvk = statefulSetGVK
+	}
+
+	source := &unstructured.Unstructured{}
+	source.SetGroupVersionKind(gvk)
+
+	if err := r.Get(ctx, types.NamespacedName{Name: link.Name, Namespace: namespace}, source); err != nil {
+		return 0, err
+	}
+
+	// a workload without status.replicas (yet) runs no pods:
+	replicas, _, err := unstructured.NestedInt64(source.Object, "status", "replicas")
+	if err != nil {
+		return 0, err
+	}
+	return linkedValue(int32(replicas), link), nil
+}
+
+// linkedValue works out the value of a linked scale step from the replicas of the workload it follows:
+// the replicas times the ratio rounded up, plus the offset - clamped to minReplicas and maxReplicas (and never below 0).
+func linkedValue(sourceReplicas int32, link *autoscalingv1.ReplicaLink) int32 {
+	ratioMilli := int64(1000)
+	if link.Ratio != nil {
+		ratioMilli = link.Ratio.MilliValue()
+	}
+
+	// ceil(replicas * ratio) in thousandths:
+	value := int32((int64(sourceReplicas)*ratioMilli+999)/1000) + link.Offset
+
+	if link.MinReplicas != nil && value < *link.MinReplicas {
+		value = *link.MinReplicas
+	}
+	if link.MaxReplicas != nil && value > *link.MaxReplicas {
+		value = *link.MaxReplicas
+	}
+	if value < 0 {
+		value = 0
+	}
+	return value
+}
+
+// linkingScheduledPodAutoscalers maps a workload of the given kind to the SPAs of its namespace with a scale step
+// following it - so its replica changes propagate right away.
+func (r *ScheduledPodAutoscalerReconciler) linkingScheduledPodAutoscalers(gvk schema.GroupVersionKind) func(client.Object) []reconcile.Request {
+	return func(obj client.Object) []reconcile.Request {
+		var scheduledPodAutoscalers autoscalingv1.ScheduledPodAutoscalerList
+		if err := r.List(context.Background(), &scheduledPodAutoscalers, client.InNamespace(obj.GetNamespace()), client.MatchingFields{linkIndex: gvk.Kind + "/" + obj.GetName()}); err != nil {
+			r.Log.Error(err, "unable to list ScheduledPodAutoscalers following workload", "kind", gvk.Kind, "name", obj.GetName())
+			return nil
+		}
+
+		requests := make([]reconcile.Request, 0, len(scheduledPodAutoscalers.Items))
+		for _, spa := range scheduledPodAutoscalers.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: spa.Name, Namespace: spa.Namespace}})
+		}
+		return requests
+	}
+}
@@ -0,0 +1,51 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var _ = Describe("Linked scale steps", func() {
+	int32Ptr := func(i int32) *int32 { return &i }
+	ratio := func(s string) *resource.Quantity {
+		q := resource.MustParse(s)
+		return &q
+	}
+
+	DescribeTable("linkedValue follows the replicas of the linked workload",
+		func(sourceReplicas int32, link autoscalingv1.ReplicaLink, expected int32) {
+			Expect(linkedValue(sourceReplicas, &link)).To(Equal(expected))
+		},
+		Entry("the same replicas without a ratio", int32(6), autoscalingv1.ReplicaLink{}, int32(6)),
+		Entry("a whole ratio", int32(6), autoscalingv1.ReplicaLink{Ratio: ratio("2")}, int32(12)),
+		Entry("a fractional ratio rounded up", int32(5), autoscalingv1.ReplicaLink{Ratio: ratio("0.5")}, int32(3)),
+		Entry("a fractional ratio that comes out even", int32(6), autoscalingv1.ReplicaLink{Ratio: ratio("0.5")}, int32(3)),
+		Entry("a milli ratio rounded up to a replica", int32(1), autoscalingv1.ReplicaLink{Ratio: ratio("0.001")}, int32(1)),
+		Entry("no replicas stay none", int32(0), autoscalingv1.ReplicaLink{Ratio: ratio("0.3")}, int32(0)),
+		Entry("an offset after the ratio", int32(5), autoscalingv1.ReplicaLink{Ratio: ratio("0.5"), Offset: 2}, int32(5)),
+		Entry("a negative offset", int32(6), autoscalingv1.ReplicaLink{Offset: -2}, int32(4)),
+		Entry("clamped to minReplicas", int32(1), autoscalingv1.ReplicaLink{MinReplicas: int32Ptr(3)}, int32(3)),
+		Entry("clamped to maxReplicas", int32(20), autoscalingv1.ReplicaLink{Ratio: ratio("1.5"), MaxReplicas: int32Ptr(10)}, int32(10)),
+		Entry("never below zero", int32(1), autoscalingv1.ReplicaLink{Offset: -5}, int32(0)),
+	)
+})
@@ -0,0 +1,35 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// changedByHand tells whether the scaled field was changed by hand - it holds neither what the controller applied last,
+// nor what the schedule asks for. Without a grace period manual changes are never respected.
+func changedByHand(gracePeriod *metav1.Duration, current *int32, lastApplied *int32, required int32) bool {
+	return gracePeriod != nil && current != nil && lastApplied != nil && *current != *lastApplied && *current != required
+}
+
+// manualOverrideRemaining returns how much of the grace period is left of a manual change noticed at overrideTime
+// - zero or less once it expired.
+func manualOverrideRemaining(overrideTime time.Time, gracePeriod time.Duration, now time.Time) time.Duration {
+	return overrideTime.Add(gracePeriod).Sub(now)
+}
@@ -0,0 +1,61 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	kautoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Manual scaling grace period", func() {
+	int32Ptr := func(i int32) *int32 { return &i }
+	gracePeriod := &metav1.Duration{Duration: 30 * time.Minute}
+
+	DescribeTable("changedByHand tells changes by hand from the schedule",
+		func(gracePeriod *metav1.Duration, current *int32, lastApplied *int32, required int32, expected bool) {
+			Expect(changedByHand(gracePeriod, current, lastApplied, required)).To(Equal(expected))
+		},
+		Entry("changed by hand", gracePeriod, int32Ptr(8), int32Ptr(4), int32(4), true),
+		Entry("changed by hand while the schedule moves on", gracePeriod, int32Ptr(8), int32Ptr(4), int32(2), true),
+		Entry("still what was applied", gracePeriod, int32Ptr(4), int32Ptr(4), int32(2), false),
+		Entry("changed to what the schedule asks for", gracePeriod, int32Ptr(2), int32Ptr(4), int32(2), false),
+		Entry("nothing applied yet", gracePeriod, int32Ptr(8), nil, int32(4), false),
+		Entry("no current value", gracePeriod, nil, int32Ptr(4), int32(4), false),
+		Entry("no grace period", nil, int32Ptr(8), int32Ptr(4), int32(4), false),
+	)
+
+	It("notices manual changes of resources scaled through /scale", func() {
+		target := &scaleTarget{resourceType: "scale", scale: &kautoscalingv1.Scale{Spec: kautoscalingv1.ScaleSpec{Replicas: 8}}}
+		Expect(changedByHand(gracePeriod, target.replicas(), int32Ptr(4), 4)).To(BeTrue())
+	})
+
+	DescribeTable("manualOverrideRemaining counts down the grace period from when the change was noticed",
+		func(sinceOverride time.Duration, expected time.Duration) {
+			overrideTime := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+			Expect(manualOverrideRemaining(overrideTime, gracePeriod.Duration, overrideTime.Add(sinceOverride))).To(Equal(expected))
+		},
+		Entry("just noticed", time.Duration(0), 30*time.Minute),
+		Entry("half way", 15*time.Minute, 15*time.Minute),
+		Entry("expired exactly", 30*time.Minute, time.Duration(0)),
+		Entry("expired", 45*time.Minute, -15*time.Minute),
+	)
+})
@@ -0,0 +1,340 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+const (
+	// placeholderLabel marks the placeholder pods of an SPA - its value is the name of the SPA:
+	placeholderLabel = "spa.sarmadabualkaz.io/placeholder"
+
+	// placeholderTargetLabel holds the name of the target a placeholder pod runs ahead of:
+	placeholderTargetLabel = "spa.sarmadabualkaz.io/placeholder-target"
+
+	// placeholderTemplateAnnotation holds a hash of the pod spec a placeholder pod was created with:
+	placeholderTemplateAnnotation = "spa.sarmadabualkaz.io/placeholder-template"
+
+	// placeholderPriorityClass is the PriorityClass of placeholder pods - below every workload, so any pod preempts them
+	// (and just above the cluster autoscaler's default expendable-pods cutoff of -10, so nodes are still added for them):
+	placeholderPriorityClass = "spa-placeholder"
+	placeholderPriority      = int64(-1)
+
+	// defaultPlaceholderImage is run by placeholder pods unless the controller is given another (e.g. of a mirror):
+	defaultPlaceholderImage = "registry.k8s.io/pause:3.9"
+
+	// placeholderUser is the (non-root) user the pause image runs as:
+	placeholderUser = int64(65535)
+)
+
+var (
+	// defaultPlaceholdersAhead is used if placeholders.ahead is unset:
+	defaultPlaceholdersAhead = 10 * time.Minute
+
+	priorityClassGVK = schema.GroupVersionKind{Group: "scheduling.k8s.io", Kind: "PriorityClass", Version: "v1"}
+)
+
+// placeholderName returns the name prefix of the placeholder pods of a target.
+func placeholderName(spa *autoscalingv1.ScheduledPodAutoscaler, target *scaleTarget) string {
+	return fmt.Sprintf("%s-placeholder-%s", spa.Name, target.key.Name)
+}
+
+// placeholdersAhead returns how long before a scale-up the placeholders are created.
+func placeholdersAhead(policy *autoscalingv1.PlaceholderPolicy) time.Duration {
+	if policy.Ahead == nil {
+		return defaultPlaceholdersAhead
+	}
+	return policy.Ahead.Duration
+}
+
+// placeholderReplicas returns how many placeholder pods make room for scaling the target's workload up to
+// scaleUpReplicas - zero for targets without a pod template of their own (ScaledObjects).
+func (r *ScheduledPodAutoscalerReconciler) placeholderReplicas(ctx context.Context, target *scaleTarget, scaleUpReplicas int32) (int32, error) {
+	workload, err := r.podWorkload(ctx, target)
+	if err != nil || workload == nil {
+		return 0, err
+	}
+
+	current, _, err := unstructured.NestedInt64(workload.Object, "spec", "replicas")
+	if err != nil {
+		return 0, err
+	}
+	if int64(scaleUpReplicas) <= current {
+		return 0, nil
+	}
+	return scaleUpReplicas - int32(current), nil
+}
+
+// placeholderPodTemplate returns a pod template requesting (and limited to) what the workload's pods request - running
+// the (pause) image with the placeholder PriorityClass on the nodes the workload's pods may run on. The pods are
+// locked down as the restricted Pod Security Standard requires, so they are admitted in any namespace.
+func placeholderPodTemplate(labels map[string]string, podSpec *corev1.PodSpec, image string) corev1.PodTemplateSpec {
+	gracePeriod := int64(0)
+	runAsNonRoot, runAsUser, allowPrivilegeEscalation, readOnlyRootFilesystem := true, placeholderUser, false, true
+
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: labels},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "placeholder",
+				Image: image,
+				// limits too - so the placeholders are admitted by quotas on limits.cpu and limits.memory like the pods:
+				Resources: corev1.ResourceRequirements{Requests: podRequests(podSpec), Limits: podLimits(podSpec)},
+				SecurityContext: &corev1.SecurityContext{
+					AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+					ReadOnlyRootFilesystem:   &readOnlyRootFilesystem,
+					Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+				},
+			}},
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsNonRoot:   &runAsNonRoot,
+				RunAsUser:      &runAsUser,
+				RunAsGroup:     &runAsUser,
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			},
+			PriorityClassName:             placeholderPriorityClass,
+			TerminationGracePeriodSeconds: &gracePeriod,
+			NodeSelector:                  podSpec.NodeSelector,
+			Tolerations:                   podSpec.Tolerations,
+		},
+	}
+
+	// only node affinity is kept - (anti-)affinity to other pods refers to the workload's own labels:
+	if podSpec.Affinity != nil && podSpec.Affinity.NodeAffinity != nil {
+		template.Spec.Affinity = &corev1.Affinity{NodeAffinity: podSpec.Affinity.NodeAffinity}
+	}
+	return template
+}
+
+// ensurePlaceholderPriorityClass creates the placeholder PriorityClass if it doesn't exist yet.
+func (r *ScheduledPodAutoscalerReconciler) ensurePlaceholderPriorityClass(ctx context.Context) error {
+	priorityClass := &unstructured.Unstructured{}
+	priorityClass.SetGroupVersionKind(priorityClassGVK)
+
+	err := r.Get(ctx, client.ObjectKey{Name: placeholderPriorityClass}, priorityClass)
+	if err == nil || !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	priorityClass.SetName(placeholderPriorityClass)
+	priorityClass.Object["value"] = placeholderPriority
+	priorityClass.Object["globalDefault"] = false
+	priorityClass.Object["preemptionPolicy"] = string(corev1.PreemptNever)
+	priorityClass.Object["description"] = "placeholder pods of ScheduledPodAutoscalers - preempted by any other pod"
+
+	if err := r.Create(ctx, priorityClass, client.FieldOwner(fieldManager)); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// reconcilePlaceholders brings the placeholder pods of the target to the given replicas - creating them (sized like
+// the target's pods and owned by the SPA) or deleting them. Placeholders are bare pods: none comes back once deleted
+// (or preempted) unless the controller creates it - and no Deployment is needed to run them.
+func (r *ScheduledPodAutoscalerReconciler) reconcilePlaceholders(ctx context.Context, spa *autoscalingv1.ScheduledPodAutoscaler, eventObject runtime.Object, target *scaleTarget, status *autoscalingv1.TargetStatus, replicas int32) error {
+	// placeholder pods are read uncached - a cache of pods would hold every pod of the cluster:
+	pods := &unstructured.UnstructuredList{}
+	pods.SetGroupVersionKind(podListGVK)
+	if err := r.apiReader().List(ctx, pods, client.InNamespace(spa.Namespace), client.MatchingLabels{placeholderLabel: spa.Name, placeholderTargetLabel: target.key.Name}); err != nil {
+		return err
+	}
+
+	if replicas == 0 {
+		if err := r.deletePlaceholders(ctx, spa, pods.Items); err != nil {
+			return err
+		}
+		if status.PlaceholderReplicas > 0 {
+			r.Recorder.Eventf(eventObject, corev1.EventTypeNormal, "PlaceholdersRemoved", "removed %d placeholder pods of %s", status.PlaceholderReplicas, target)
+		}
+		status.PlaceholderReplicas = 0
+		return nil
+	}
+
+	workload, err := r.podWorkload(ctx, target)
+	if err != nil {
+		return err
+	}
+	podSpec, err := podTemplateSpec(workload)
+	if err != nil || podSpec == nil {
+		return err
+	}
+
+	if err := r.ensurePlaceholderPriorityClass(ctx); err != nil {
+		return err
+	}
+
+	labels := map[string]string{placeholderLabel: spa.Name, placeholderTargetLabel: target.key.Name}
+	podTemplate := placeholderPodTemplate(labels, podSpec, r.placeholderImage())
+
+	// pods of an outdated spec (or that are done) are replaced - the hash stands in for comparing specs, which the API
+	// server fills in with defaults that would never compare equal:
+	specJSON, err := json.Marshal(podTemplate.Spec)
+	if err != nil {
+		return err
+	}
+	hash := fnv.New32a()
+	hash.Write(specJSON)
+	templateHash := fmt.Sprintf("%x", hash.Sum32())
+
+	var running, replaced []unstructured.Unstructured
+	for _, pod := range pods.Items {
+		phase, _, _ := unstructured.NestedString(pod.Object, "status", "phase")
+		switch {
+		case pod.GetDeletionTimestamp() != nil:
+			// already on its way out
+		case pod.GetAnnotations()[placeholderTemplateAnnotation] != templateHash,
+			phase == string(corev1.PodSucceeded), phase == string(corev1.PodFailed):
+			replaced = append(replaced, pod)
+		default:
+			running = append(running, pod)
+		}
+	}
+
+	// surplus placeholders are removed - unscheduled ones first:
+	if len(running) > int(replicas) {
+		sort.SliceStable(running, func(i, j int) bool {
+			iNode, _, _ := unstructured.NestedString(running[i].Object, "spec", "nodeName")
+			jNode, _, _ := unstructured.NestedString(running[j].Object, "spec", "nodeName")
+			return iNode == "" && jNode != ""
+		})
+		replaced = append(replaced, running[:len(running)-int(replicas)]...)
+		running = running[len(running)-int(replicas):]
+	}
+	if err := r.deletePlaceholders(ctx, spa, replaced); err != nil {
+		return err
+	}
+
+	for i := len(running); i < int(replicas); i++ {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: placeholderName(spa, target) + "-",
+				Namespace:    spa.Namespace,
+				Labels:       labels,
+				Annotations:  map[string]string{placeholderTemplateAnnotation: templateHash},
+			},
+			Spec: podTemplate.Spec,
+		}
+		object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+		if err != nil {
+			return err
+		}
+
+		placeholder := &unstructured.Unstructured{Object: object}
+		placeholder.SetGroupVersionKind(podGVK)
+		if err := controllerutil.SetControllerReference(spa, placeholder, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, placeholder, client.FieldOwner(fieldManager)); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case status.PlaceholderReplicas == 0:
+		r.Recorder.Eventf(eventObject, corev1.EventTypeNormal, "PlaceholdersCreated", "created %d placeholder pods requesting %s each ahead of scaling up %s", replicas, describeRequests(podRequests(podSpec)), target)
+	case replicas != status.PlaceholderReplicas:
+		r.Recorder.Eventf(eventObject, corev1.EventTypeNormal, "PlaceholdersResized", "resized placeholder pods of %s from %d to %d", target, status.PlaceholderReplicas, replicas)
+	}
+	status.PlaceholderReplicas = replicas
+	return nil
+}
+
+// deletePlaceholders deletes the given placeholder pods controlled by the SPA.
+func (r *ScheduledPodAutoscalerReconciler) deletePlaceholders(ctx context.Context, spa *autoscalingv1.ScheduledPodAutoscaler, pods []unstructured.Unstructured) error {
+	for i := range pods {
+		if !metav1.IsControlledBy(&pods[i], spa) {
+			continue
+		}
+		if err := r.Delete(ctx, &pods[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// placeholderImage returns the image run by placeholder pods.
+func (r *ScheduledPodAutoscalerReconciler) placeholderImage() string {
+	if r.PlaceholderImage != "" {
+		return r.PlaceholderImage
+	}
+	return defaultPlaceholderImage
+}
+
+// hasPlaceholders tells whether the SPA may have placeholder pods - it runs them, or ran some that weren't removed yet.
+func hasPlaceholders(spa *autoscalingv1.ScheduledPodAutoscaler) bool {
+	if spa.Spec.Placeholders != nil || spa.Status.PlaceholderReplicas > 0 {
+		return true
+	}
+	for _, entry := range spa.Status.Targets {
+		if entry.PlaceholderReplicas > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// removeStalePlaceholders deletes the placeholder pods of the SPA that belong to none of the given targets - targets
+// no longer matching the selector, or all of them once spec.placeholders is removed (forgetting them in status).
+func (r *ScheduledPodAutoscalerReconciler) removeStalePlaceholders(ctx context.Context, spa *autoscalingv1.ScheduledPodAutoscaler, targets []*scaleTarget) error {
+	pods := &unstructured.UnstructuredList{}
+	pods.SetGroupVersionKind(podListGVK)
+	if err := r.apiReader().List(ctx, pods, client.InNamespace(spa.Namespace), client.MatchingLabels{placeholderLabel: spa.Name}); err != nil {
+		return err
+	}
+
+	kept := make(map[string]bool, len(targets))
+	if spa.Spec.Placeholders != nil {
+		for _, target := range targets {
+			kept[target.key.Name] = true
+		}
+	}
+
+	var stale []unstructured.Unstructured
+	for _, pod := range pods.Items {
+		if !kept[pod.GetLabels()[placeholderTargetLabel]] {
+			stale = append(stale, pod)
+		}
+	}
+	if err := r.deletePlaceholders(ctx, spa, stale); err != nil {
+		return err
+	}
+
+	if spa.Spec.Placeholders == nil {
+		spa.Status.PlaceholderReplicas = 0
+		for i := range spa.Status.Targets {
+			spa.Status.Targets[i].PlaceholderReplicas = 0
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var _ = Describe("Placeholder pods", func() {
+	It("are sized like the workload's pods and run where they may run", func() {
+		nodeAffinity := &corev1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{{
+				Key: "pool", Operator: corev1.NodeSelectorOpIn, Values: []string{"batch"},
+			}}}},
+		}}
+		podSpec := &corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Image: "app:1",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+					Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+				},
+			}},
+			PriorityClassName: "high",
+			NodeSelector:      map[string]string{"zone": "a"},
+			Tolerations:       []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+			Affinity: &corev1.Affinity{
+				NodeAffinity:    nodeAffinity,
+				PodAntiAffinity: &corev1.PodAntiAffinity{},
+			},
+		}
+		labels := map[string]string{placeholderLabel: "spa", placeholderTargetLabel: "app"}
+
+		template := placeholderPodTemplate(labels, podSpec, "mirror.example.com/pause:3.9")
+
+		Expect(template.Labels).To(Equal(labels))
+		Expect(template.Spec.PriorityClassName).To(Equal(placeholderPriorityClass))
+		Expect(template.Spec.NodeSelector).To(Equal(podSpec.NodeSelector))
+		Expect(template.Spec.Tolerations).To(Equal(podSpec.Tolerations))
+		Expect(template.Spec.Affinity).To(Equal(&corev1.Affinity{NodeAffinity: nodeAffinity}))
+
+		Expect(template.Spec.Containers).To(HaveLen(1))
+		Expect(template.Spec.Containers[0].Image).To(Equal("mirror.example.com/pause:3.9"))
+		Expect(*template.Spec.SecurityContext.RunAsNonRoot).To(BeTrue())
+		Expect(*template.Spec.Containers[0].SecurityContext.AllowPrivilegeEscalation).To(BeFalse())
+		Expect(template.Spec.Containers[0].Resources.Requests).To(Equal(podRequests(podSpec)))
+		Expect(template.Spec.Containers[0].Resources.Limits).To(Equal(podLimits(podSpec)))
+	})
+
+	It("are only looked for while the SPA runs them or has some left", func() {
+		spa := &autoscalingv1.ScheduledPodAutoscaler{}
+		Expect(hasPlaceholders(spa)).To(BeFalse())
+
+		spa.Status.Targets = []autoscalingv1.NamedTargetStatus{{Name: "web", TargetStatus: autoscalingv1.TargetStatus{PlaceholderReplicas: 2}}}
+		Expect(hasPlaceholders(spa)).To(BeTrue())
+
+		spa.Status.Targets = nil
+		spa.Spec.Placeholders = &autoscalingv1.PlaceholderPolicy{}
+		Expect(hasPlaceholders(spa)).To(BeTrue())
+	})
+})
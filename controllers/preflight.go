@@ -0,0 +1,435 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var (
+	resourceQuotaListGVK = schema.GroupVersionKind{Group: "", Kind: "ResourceQuotaList", Version: "v1"}
+	nodeListGVK          = schema.GroupVersionKind{Group: "", Kind: "NodeList", Version: "v1"}
+	podListGVK           = schema.GroupVersionKind{Group: "", Kind: "PodList", Version: "v1"}
+)
+
+// unlimitedPods is returned by the fit checks when nothing limits the number of pods:
+const unlimitedPods = int64(-1)
+
+// podListPageSize is the number of pods listed at a time by the node capacity check:
+const podListPageSize = 500
+
+// podWorkload returns the workload creating the target's pods - the target itself, or the resource scaled by an
+// HPA - nil for targets without a pod template of their own (ScaledObjects), and for workloads that aren't read
+// (see cachedWorkloadKind).
+func (r *ScheduledPodAutoscalerReconciler) podWorkload(ctx context.Context, target *scaleTarget) (*unstructured.Unstructured, error) {
+	workload := target
+	switch target.resourceType {
+	case "hpa", "hpaOperator":
+		scaled, err := r.hpaScaleTarget(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		workload = scaled
+	case "scaledObject":
+		return nil, nil
+	}
+	if !cachedWorkloadKind(workload.gvk) {
+		return nil, nil
+	}
+
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(workload.gvk)
+	if err := r.Get(ctx, workload.key, object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// podTemplateSpec returns the pod spec of a workload's spec.template - nil if it has none.
+func podTemplateSpec(workload *unstructured.Unstructured) (*corev1.PodSpec, error) {
+	template, found, err := unstructured.NestedMap(workload.Object, "spec", "template", "spec")
+	if err != nil || !found {
+		return nil, err
+	}
+
+	podSpec := &corev1.PodSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(template, podSpec); err != nil {
+		return nil, err
+	}
+	return podSpec, nil
+}
+
+// podRequests returns the CPU and memory requests of a pod - the sum of its containers, or the largest init container
+// if that is more (init containers run one at a time).
+func podRequests(podSpec *corev1.PodSpec) corev1.ResourceList {
+	return podResources(podSpec, func(resources corev1.ResourceRequirements) corev1.ResourceList { return resources.Requests })
+}
+
+// podLimits returns the CPU and memory limits of a pod - summed up like podRequests.
+func podLimits(podSpec *corev1.PodSpec) corev1.ResourceList {
+	return podResources(podSpec, func(resources corev1.ResourceRequirements) corev1.ResourceList { return resources.Limits })
+}
+
+// podResources sums up the CPU and memory of the containers of a pod (as returned by of) - taking the largest init
+// container instead if that is more.
+func podResources(podSpec *corev1.PodSpec, of func(corev1.ResourceRequirements) corev1.ResourceList) corev1.ResourceList {
+	sums := corev1.ResourceList{}
+	for _, container := range podSpec.Containers {
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			if quantity, ok := of(container.Resources)[name]; ok {
+				sum := sums[name]
+				sum.Add(quantity)
+				sums[name] = sum
+			}
+		}
+	}
+
+	for _, container := range podSpec.InitContainers {
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			if quantity, ok := of(container.Resources)[name]; ok && quantity.Cmp(sums[name]) > 0 {
+				sums[name] = quantity
+			}
+		}
+	}
+	return sums
+}
+
+// fittingPods returns how many pods requesting perPod fit into headroom - unlimitedPods if a pod requests none of it.
+func fittingPods(headroom resource.Quantity, perPod resource.Quantity) int64 {
+	if perPod.IsZero() {
+		return unlimitedPods
+	}
+	if headroom.Sign() <= 0 {
+		return 0
+	}
+	return headroom.MilliValue() / perPod.MilliValue()
+}
+
+// fewerPods returns the lower of two pod counts - unlimitedPods being the highest.
+func fewerPods(a int64, b int64) int64 {
+	if a == unlimitedPods || (b != unlimitedPods && b < a) {
+		return b
+	}
+	return a
+}
+
+// quotaFit returns how many pods of the given spec fit into the headroom of the namespace's ResourceQuotas - along
+// with the quota (and resource) limiting them. The given number of placeholder pods run for them is counted as
+// headroom: they are removed as the scale-up starts.
+func (r *ScheduledPodAutoscalerReconciler) quotaFit(ctx context.Context, namespace string, podSpec *corev1.PodSpec, placeholders int32) (int64, string, error) {
+	quotas := &unstructured.UnstructuredList{}
+	quotas.SetGroupVersionKind(resourceQuotaListGVK)
+	if err := r.List(ctx, quotas, client.InNamespace(namespace)); err != nil {
+		return 0, "", err
+	}
+
+	fit, limitedBy := unlimitedPods, ""
+	for _, item := range quotas.Items {
+		var quota corev1.ResourceQuota
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &quota); err != nil {
+			return 0, "", err
+		}
+
+		if pods, name := quotaPodFit(&quota, podSpec, placeholders); fewerPods(fit, pods) != fit {
+			fit, limitedBy = pods, fmt.Sprintf("%s of ResourceQuota %s", name, quota.Name)
+		}
+	}
+	return fit, limitedBy, nil
+}
+
+// quotaPodFit returns how many pods of the given spec fit into the headroom of a ResourceQuota - along with the
+// resource limiting them. Quotas whose scopes don't cover the pods don't limit them. What the given number of
+// placeholder pods of the spec use of the quota is counted as headroom.
+func quotaPodFit(quota *corev1.ResourceQuota, podSpec *corev1.PodSpec, placeholders int32) (int64, corev1.ResourceName) {
+	if !quotaCoversPod(quota, podSpec) {
+		return unlimitedPods, ""
+	}
+
+	perPod := quotaUsage(podSpec)
+
+	placeholderSpec := placeholderPodTemplate(nil, podSpec, defaultPlaceholderImage).Spec
+	perPlaceholder := corev1.ResourceList{}
+	if placeholders > 0 && quotaCoversPod(quota, &placeholderSpec) {
+		perPlaceholder = quotaUsage(&placeholderSpec)
+	}
+
+	// in the order of the resource names - so the same resource is named for a tie:
+	names := make([]string, 0, len(quota.Status.Hard))
+	for name := range quota.Status.Hard {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	fit, limitedBy := unlimitedPods, corev1.ResourceName("")
+	for _, name := range names {
+		request, ok := perPod[corev1.ResourceName(name)]
+		if !ok {
+			continue
+		}
+
+		headroom := quota.Status.Hard[corev1.ResourceName(name)].DeepCopy()
+		headroom.Sub(quota.Status.Used[corev1.ResourceName(name)])
+		if placeholder, ok := perPlaceholder[corev1.ResourceName(name)]; ok {
+			headroom.Add(*resource.NewMilliQuantity(placeholder.MilliValue()*int64(placeholders), placeholder.Format))
+		}
+
+		if pods := fittingPods(headroom, request); fewerPods(fit, pods) != fit {
+			fit, limitedBy = pods, corev1.ResourceName(name)
+		}
+	}
+	return fit, limitedBy
+}
+
+// quotaUsage returns what a pod of the given spec uses of the resources limited by ResourceQuotas.
+func quotaUsage(podSpec *corev1.PodSpec) corev1.ResourceList {
+	requests, limits := podRequests(podSpec), podLimits(podSpec)
+	return corev1.ResourceList{
+		corev1.ResourcePods:           resource.MustParse("1"),
+		corev1.ResourceCPU:            requests[corev1.ResourceCPU],
+		corev1.ResourceRequestsCPU:    requests[corev1.ResourceCPU],
+		corev1.ResourceMemory:         requests[corev1.ResourceMemory],
+		corev1.ResourceRequestsMemory: requests[corev1.ResourceMemory],
+		corev1.ResourceLimitsCPU:      limits[corev1.ResourceCPU],
+		corev1.ResourceLimitsMemory:   limits[corev1.ResourceMemory],
+	}
+}
+
+// quotaCoversPod tells whether the scopes of a ResourceQuota (spec.scopes and spec.scopeSelector) cover pods of the
+// given spec. Scopes the controller doesn't know are taken to cover them.
+func quotaCoversPod(quota *corev1.ResourceQuota, podSpec *corev1.PodSpec) bool {
+	selectors := make([]corev1.ScopedResourceSelectorRequirement, 0, len(quota.Spec.Scopes))
+	for _, scope := range quota.Spec.Scopes {
+		selectors = append(selectors, corev1.ScopedResourceSelectorRequirement{ScopeName: scope, Operator: corev1.ScopeSelectorOpExists})
+	}
+	if quota.Spec.ScopeSelector != nil {
+		selectors = append(selectors, quota.Spec.ScopeSelector.MatchExpressions...)
+	}
+
+	// pods are BestEffort without requests or limits of any container:
+	bestEffort := len(podRequests(podSpec)) == 0 && len(podLimits(podSpec)) == 0
+
+	for _, selector := range selectors {
+		covered := true
+		switch selector.ScopeName {
+		case corev1.ResourceQuotaScopeTerminating:
+			covered = podSpec.ActiveDeadlineSeconds != nil
+		case corev1.ResourceQuotaScopeNotTerminating:
+			covered = podSpec.ActiveDeadlineSeconds == nil
+		case corev1.ResourceQuotaScopeBestEffort:
+			covered = bestEffort
+		case corev1.ResourceQuotaScopeNotBestEffort:
+			covered = !bestEffort
+		case corev1.ResourceQuotaScopePriorityClass:
+			covered = priorityClassSelected(selector, podSpec.PriorityClassName)
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// priorityClassSelected tells whether a PriorityClass scope selector selects pods of the named priority class.
+func priorityClassSelected(selector corev1.ScopedResourceSelectorRequirement, priorityClassName string) bool {
+	listed := false
+	for _, value := range selector.Values {
+		if value == priorityClassName {
+			listed = true
+		}
+	}
+
+	switch selector.Operator {
+	case corev1.ScopeSelectorOpIn:
+		return listed
+	case corev1.ScopeSelectorOpNotIn:
+		return !listed
+	case corev1.ScopeSelectorOpExists:
+		return priorityClassName != ""
+	case corev1.ScopeSelectorOpDoesNotExist:
+		return priorityClassName == ""
+	}
+	return true
+}
+
+// nodeFit returns how many pods with the given requests fit into the allocatable capacity of the schedulable nodes
+// left over by the requests of the pods running on them - placeholder pods leave their room to any pod. Nodes and pods are listed through the uncached reader -
+// a cache would keep every pod of the cluster in the controller's memory from the first check on - and pods are
+// listed in pages of podListPageSize, so only a page of them is held at a time.
+func (r *ScheduledPodAutoscalerReconciler) nodeFit(ctx context.Context, requests corev1.ResourceList) (int64, error) {
+	nodes := &unstructured.UnstructuredList{}
+	nodes.SetGroupVersionKind(nodeListGVK)
+	if err := r.apiReader().List(ctx, nodes); err != nil {
+		return 0, err
+	}
+
+	headroom := corev1.ResourceList{}
+	schedulable := map[string]bool{}
+	for _, item := range nodes.Items {
+		var node corev1.Node
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &node); err != nil {
+			return 0, err
+		}
+		if node.Spec.Unschedulable {
+			continue
+		}
+
+		schedulable[node.Name] = true
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourcePods} {
+			sum := headroom[name]
+			sum.Add(node.Status.Allocatable[name])
+			headroom[name] = sum
+		}
+	}
+
+	for next := ""; ; {
+		pods := &unstructured.UnstructuredList{}
+		pods.SetGroupVersionKind(podListGVK)
+		if err := r.apiReader().List(ctx, pods, client.Limit(podListPageSize), client.Continue(next)); err != nil {
+			return 0, err
+		}
+
+		for _, item := range pods.Items {
+			var pod corev1.Pod
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &pod); err != nil {
+				return 0, err
+			}
+			if _, placeholder := pod.Labels[placeholderLabel]; placeholder || !schedulable[pod.Spec.NodeName] ||
+				pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				continue
+			}
+
+			used := podRequests(&pod.Spec)
+			used[corev1.ResourcePods] = resource.MustParse("1")
+			for name, quantity := range used {
+				left := headroom[name]
+				left.Sub(quantity)
+				headroom[name] = left
+			}
+		}
+
+		if next = pods.GetContinue(); next == "" {
+			break
+		}
+	}
+
+	fit := unlimitedPods
+	fit = fewerPods(fit, fittingPods(headroom[corev1.ResourcePods], resource.MustParse("1")))
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		fit = fewerPods(fit, fittingPods(headroom[name], requests[name]))
+	}
+	return fit, nil
+}
+
+// preflightScaleUp checks whether the pods added by scaling the target to the required replicas fit into the quotas
+// (and nodes) - setting the InsufficientCapacity condition and recording a warning event if they don't. It returns
+// the replicas to scale to: the required ones - or as many as fit with the Cap action.
+func (r *ScheduledPodAutoscalerReconciler) preflightScaleUp(ctx context.Context, eventObject runtime.Object, target *scaleTarget, status *autoscalingv1.TargetStatus, policy *autoscalingv1.PreflightPolicy, required int32) (int32, error) {
+	workload, err := r.podWorkload(ctx, target)
+	if err != nil || workload == nil {
+		return required, err
+	}
+
+	podSpec, err := podTemplateSpec(workload)
+	if err != nil || podSpec == nil {
+		return required, err
+	}
+
+	current, _, err := unstructured.NestedInt64(workload.Object, "spec", "replicas")
+	if err != nil {
+		return required, err
+	}
+
+	extra := int64(required) - current
+	if extra <= 0 {
+		meta.RemoveStatusCondition(&status.Conditions, autoscalingv1.ConditionInsufficientCapacity)
+		return required, nil
+	}
+
+	requests := podRequests(podSpec)
+	fit, limitedBy, err := r.quotaFit(ctx, target.key.Namespace, podSpec, status.PlaceholderReplicas)
+	if err != nil {
+		return required, err
+	}
+
+	if policy.CheckNodeCapacity {
+		nodes, err := r.nodeFit(ctx, requests)
+		if err != nil {
+			return required, err
+		}
+		if fewerPods(fit, nodes) != fit {
+			fit, limitedBy = nodes, "allocatable capacity of the nodes"
+		}
+	}
+
+	if fit == unlimitedPods || extra <= fit {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    autoscalingv1.ConditionInsufficientCapacity,
+			Status:  metav1.ConditionFalse,
+			Reason:  "ScaleUpFits",
+			Message: fmt.Sprintf("%d more pods of %s fit", extra, workload.GetName()),
+		})
+		return required, nil
+	}
+
+	message := fmt.Sprintf("only %d of %d more pods of %s (requesting %s each) fit - limited by %s",
+		fit, extra, workload.GetName(), describeRequests(requests), limitedBy)
+
+	capped := required
+	if policy.Action == autoscalingv1.PreflightActionCap {
+		capped = int32(current + fit)
+		message += fmt.Sprintf(" - scaling up to %d replicas instead of %d", capped, required)
+	}
+
+	if condition := meta.FindStatusCondition(status.Conditions, autoscalingv1.ConditionInsufficientCapacity); condition == nil || condition.Status != metav1.ConditionTrue || condition.Message != message {
+		r.Recorder.Event(eventObject, corev1.EventTypeWarning, autoscalingv1.ConditionInsufficientCapacity, message)
+	}
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    autoscalingv1.ConditionInsufficientCapacity,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ScaleUpDoesNotFit",
+		Message: message,
+	})
+	return capped, nil
+}
+
+// describeRequests lists CPU and memory requests, e.g. "cpu=500m, memory=1Gi".
+func describeRequests(requests corev1.ResourceList) string {
+	var parts []string
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if quantity, ok := requests[name]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%s", name, quantity.String()))
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,194 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var _ = Describe("Pre-flight capacity check", func() {
+	resources := func(cpu string, memory string) corev1.ResourceList {
+		list := corev1.ResourceList{}
+		if cpu != "" {
+			list[corev1.ResourceCPU] = resource.MustParse(cpu)
+		}
+		if memory != "" {
+			list[corev1.ResourceMemory] = resource.MustParse(memory)
+		}
+		return list
+	}
+	container := func(requests corev1.ResourceList, limits corev1.ResourceList) corev1.Container {
+		return corev1.Container{Resources: corev1.ResourceRequirements{Requests: requests, Limits: limits}}
+	}
+	// a pod requesting 500m/1Gi, limited to 1/2Gi:
+	podSpec := func() *corev1.PodSpec {
+		return &corev1.PodSpec{Containers: []corev1.Container{container(resources("500m", "1Gi"), resources("1", "2Gi"))}}
+	}
+	quota := func(hard corev1.ResourceList, used corev1.ResourceList) *corev1.ResourceQuota {
+		return &corev1.ResourceQuota{Status: corev1.ResourceQuotaStatus{Hard: hard, Used: used}}
+	}
+
+	DescribeTable("fittingPods divides the headroom by the requests of a pod",
+		func(headroom string, perPod string, expected int64) {
+			Expect(fittingPods(resource.MustParse(headroom), resource.MustParse(perPod))).To(Equal(expected))
+		},
+		Entry("whole pods", "2", "500m", int64(4)),
+		Entry("rounding down", "1900m", "500m", int64(3)),
+		Entry("memory", "3Gi", "1Gi", int64(3)),
+		Entry("no headroom", "0", "500m", int64(0)),
+		Entry("a used up quota", "-1", "500m", int64(0)),
+		Entry("nothing requested", "0", "0", unlimitedPods),
+	)
+
+	DescribeTable("podRequests sums up the containers - or takes the largest init container",
+		func(spec corev1.PodSpec, expected corev1.ResourceList) {
+			requests := podRequests(&spec)
+			Expect(requests).To(HaveLen(len(expected)))
+			for name, quantity := range expected {
+				request := requests[name]
+				Expect(request.Cmp(quantity)).To(Equal(0), "%s: %s", name, request.String())
+			}
+		},
+		Entry("a single container", corev1.PodSpec{Containers: []corev1.Container{
+			container(resources("250m", "512Mi"), nil),
+		}}, resources("250m", "512Mi")),
+		Entry("containers are summed up", corev1.PodSpec{Containers: []corev1.Container{
+			container(resources("250m", "512Mi"), nil),
+			container(resources("750m", ""), nil),
+		}}, resources("1", "512Mi")),
+		Entry("a larger init container", corev1.PodSpec{
+			InitContainers: []corev1.Container{container(resources("2", "256Mi"), nil)},
+			Containers:     []corev1.Container{container(resources("500m", "1Gi"), nil)},
+		}, resources("2", "1Gi")),
+		Entry("no requests", corev1.PodSpec{Containers: []corev1.Container{container(nil, resources("1", ""))}}, resources("", "")),
+	)
+
+	DescribeTable("quotaPodFit returns the pods fitting into a quota and the resource limiting them",
+		func(quota *corev1.ResourceQuota, expected int64, limitedBy corev1.ResourceName) {
+			fit, name := quotaPodFit(quota, podSpec(), 0)
+			Expect(fit).To(Equal(expected))
+			Expect(name).To(Equal(limitedBy))
+		},
+		Entry("pods", quota(
+			corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10")},
+			corev1.ResourceList{corev1.ResourcePods: resource.MustParse("7")},
+		), int64(3), corev1.ResourcePods),
+		Entry("requests.cpu", quota(
+			corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("4")},
+			corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("2")},
+		), int64(4), corev1.ResourceRequestsCPU),
+		Entry("memory", quota(
+			corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("8Gi")},
+			corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("6Gi")},
+		), int64(2), corev1.ResourceMemory),
+		Entry("limits.cpu - tighter than requests.cpu", quota(
+			corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("4"), corev1.ResourceLimitsCPU: resource.MustParse("4")},
+			corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("1"), corev1.ResourceLimitsCPU: resource.MustParse("2")},
+		), int64(2), corev1.ResourceLimitsCPU),
+		Entry("limits.memory", quota(
+			corev1.ResourceList{corev1.ResourceLimitsMemory: resource.MustParse("5Gi")},
+			corev1.ResourceList{},
+		), int64(2), corev1.ResourceLimitsMemory),
+		Entry("the same fit of two resources names the first", quota(
+			corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("1"), corev1.ResourcePods: resource.MustParse("2")},
+			corev1.ResourceList{},
+		), int64(2), corev1.ResourcePods),
+		Entry("resources the pods don't use", quota(
+			corev1.ResourceList{corev1.ResourceServices: resource.MustParse("1")},
+			corev1.ResourceList{corev1.ResourceServices: resource.MustParse("1")},
+		), unlimitedPods, corev1.ResourceName("")),
+		Entry("a used up quota", quota(
+			corev1.ResourceList{corev1.ResourcePods: resource.MustParse("5")},
+			corev1.ResourceList{corev1.ResourcePods: resource.MustParse("6")},
+		), int64(0), corev1.ResourcePods),
+	)
+
+	DescribeTable("quotaCoversPod follows the scopes of a quota",
+		func(scopes []corev1.ResourceQuotaScope, selector []corev1.ScopedResourceSelectorRequirement, spec *corev1.PodSpec, expected bool) {
+			quota := &corev1.ResourceQuota{Spec: corev1.ResourceQuotaSpec{Scopes: scopes}}
+			if selector != nil {
+				quota.Spec.ScopeSelector = &corev1.ScopeSelector{MatchExpressions: selector}
+			}
+			Expect(quotaCoversPod(quota, spec)).To(Equal(expected))
+		},
+		Entry("an unscoped quota", nil, nil, podSpec(), true),
+		Entry("NotTerminating", []corev1.ResourceQuotaScope{corev1.ResourceQuotaScopeNotTerminating}, nil, podSpec(), true),
+		Entry("Terminating", []corev1.ResourceQuotaScope{corev1.ResourceQuotaScopeTerminating}, nil, podSpec(), false),
+		Entry("BestEffort", []corev1.ResourceQuotaScope{corev1.ResourceQuotaScopeBestEffort}, nil, podSpec(), false),
+		Entry("BestEffort of a pod without requests", []corev1.ResourceQuotaScope{corev1.ResourceQuotaScopeBestEffort}, nil,
+			&corev1.PodSpec{Containers: []corev1.Container{{}}}, true),
+		Entry("NotBestEffort", []corev1.ResourceQuotaScope{corev1.ResourceQuotaScopeNotBestEffort}, nil, podSpec(), true),
+		Entry("a priority class in the selector", nil, []corev1.ScopedResourceSelectorRequirement{{
+			ScopeName: corev1.ResourceQuotaScopePriorityClass, Operator: corev1.ScopeSelectorOpIn, Values: []string{"high"},
+		}}, &corev1.PodSpec{PriorityClassName: "high"}, true),
+		Entry("a priority class not in the selector", nil, []corev1.ScopedResourceSelectorRequirement{{
+			ScopeName: corev1.ResourceQuotaScopePriorityClass, Operator: corev1.ScopeSelectorOpIn, Values: []string{"high"},
+		}}, podSpec(), false),
+		Entry("a priority class excluded by the selector", nil, []corev1.ScopedResourceSelectorRequirement{{
+			ScopeName: corev1.ResourceQuotaScopePriorityClass, Operator: corev1.ScopeSelectorOpNotIn, Values: []string{"high"},
+		}}, &corev1.PodSpec{PriorityClassName: "high"}, false),
+		Entry("any priority class", nil, []corev1.ScopedResourceSelectorRequirement{{
+			ScopeName: corev1.ResourceQuotaScopePriorityClass, Operator: corev1.ScopeSelectorOpExists,
+		}}, podSpec(), false),
+		Entry("scopes and selector both have to match", []corev1.ResourceQuotaScope{corev1.ResourceQuotaScopeNotBestEffort}, []corev1.ScopedResourceSelectorRequirement{{
+			ScopeName: corev1.ResourceQuotaScopePriorityClass, Operator: corev1.ScopeSelectorOpDoesNotExist,
+		}}, podSpec(), true),
+	)
+
+	It("counts the room held by placeholder pods as headroom", func() {
+		full := quota(
+			corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10"), corev1.ResourceRequestsCPU: resource.MustParse("5")},
+			corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10"), corev1.ResourceRequestsCPU: resource.MustParse("5")},
+		)
+
+		fit, _ := quotaPodFit(full, podSpec(), 0)
+		Expect(fit).To(Equal(int64(0)))
+
+		fit, name := quotaPodFit(full, podSpec(), 3)
+		Expect(fit).To(Equal(int64(3)))
+		Expect(name).To(Equal(corev1.ResourcePods))
+	})
+
+	It("doesn't count placeholder pods outside the scope of a quota", func() {
+		scoped := quota(
+			corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10")},
+			corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10")},
+		)
+		scoped.Spec.ScopeSelector = &corev1.ScopeSelector{MatchExpressions: []corev1.ScopedResourceSelectorRequirement{{
+			ScopeName: corev1.ResourceQuotaScopePriorityClass, Operator: corev1.ScopeSelectorOpNotIn, Values: []string{placeholderPriorityClass},
+		}}}
+
+		fit, _ := quotaPodFit(scoped, podSpec(), 3)
+		Expect(fit).To(Equal(int64(0)))
+	})
+
+	It("doesn't limit pods by a quota of another scope", func() {
+		scoped := quota(
+			corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1")},
+			corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1")},
+		)
+		scoped.Spec.Scopes = []corev1.ResourceQuotaScope{corev1.ResourceQuotaScopeTerminating}
+
+		fit, name := quotaPodFit(scoped, podSpec(), 0)
+		Expect(fit).To(Equal(unlimitedPods))
+		Expect(name).To(BeEmpty())
+	})
+})
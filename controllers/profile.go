@@ -0,0 +1,114 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+// findProfile returns the named profile of the SPA - nil if there is none.
+func findProfile(spa *autoscalingv1.ScheduledPodAutoscaler, name string) *autoscalingv1.CapacityProfile {
+	for i := range spa.Spec.Profiles {
+		if spa.Spec.Profiles[i].Name == name {
+			return &spa.Spec.Profiles[i]
+		}
+	}
+	return nil
+}
+
+// profilePin returns the pin of the SPA along with where it is set - the pin annotation takes precedence over spec.pin.
+// It returns nil if the SPA isn't pinned.
+func profilePin(spa *autoscalingv1.ScheduledPodAutoscaler) (*autoscalingv1.ProfilePin, string, error) {
+	annotation, ok := spa.Annotations[autoscalingv1.PinAnnotation]
+	if !ok {
+		return spa.Spec.Pin, "spec.pin", nil
+	}
+
+	// "<profile>" or "<profile>/<duration>":
+	parts := strings.SplitN(annotation, "/", 2)
+	pin := &autoscalingv1.ProfilePin{Profile: strings.TrimSpace(parts[0])}
+
+	if len(parts) == 2 {
+		duration, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid duration in annotation %s=%q: %w", autoscalingv1.PinAnnotation, annotation, err)
+		}
+		pin.Duration = &metav1.Duration{Duration: duration}
+	}
+	return pin, "annotation", nil
+}
+
+// resolveProfile returns the profile to apply - a pin that hasn't expired yet, otherwise the profile selected by the
+// active step (nil if the step sets its value directly) - along with why it applies. A pin's duration is counted from
+// when it is first observed (recorded in status), and for pins with a duration the time until it expires is returned.
+func resolveProfile(spa *autoscalingv1.ScheduledPodAutoscaler, activeStep *autoscalingv1.ScaleSpec, stepName string, now time.Time) (*autoscalingv1.CapacityProfile, string, time.Duration, error) {
+	status := &spa.Status
+
+	pin, source, err := profilePin(spa)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	var expired string
+	if pin == nil {
+		status.ObservedPin = ""
+		status.PinExpirationTime = nil
+	} else {
+		observed := source + ":" + pin.Profile
+		if pin.Duration != nil {
+			observed += "/" + pin.Duration.Duration.String()
+		}
+
+		// a new (or changed) pin starts its duration now:
+		if observed != status.ObservedPin {
+			status.ObservedPin = observed
+			status.PinExpirationTime = nil
+			if pin.Duration != nil {
+				status.PinExpirationTime = &metav1.Time{Time: now.Add(pin.Duration.Duration)}
+			}
+		}
+
+		if status.PinExpirationTime == nil || now.Before(status.PinExpirationTime.Time) {
+			profile := findProfile(spa, pin.Profile)
+			if profile == nil {
+				return nil, "", 0, fmt.Errorf("pinned profile %q (from %s) is not one of spec.profiles", pin.Profile, source)
+			}
+
+			if status.PinExpirationTime == nil {
+				return profile, fmt.Sprintf("pinned by %s", source), 0, nil
+			}
+			return profile, fmt.Sprintf("pinned by %s until %s", source, status.PinExpirationTime.Format(time.RFC3339)), status.PinExpirationTime.Sub(now), nil
+		}
+		expired = fmt.Sprintf(" (pin by %s expired at %s)", source, status.PinExpirationTime.Format(time.RFC3339))
+	}
+
+	if activeStep.Profile == "" {
+		return nil, fmt.Sprintf("value of the %s step%s", stepName, expired), 0, nil
+	}
+
+	profile := findProfile(spa, activeStep.Profile)
+	if profile == nil {
+		return nil, "", 0, fmt.Errorf("profile %q of the %s step is not one of spec.profiles", activeStep.Profile, stepName)
+	}
+	return profile, fmt.Sprintf("selected by the %s step%s", stepName, expired), 0, nil
+}
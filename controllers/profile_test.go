@@ -0,0 +1,118 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var _ = Describe("Capacity profiles", func() {
+	now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+
+	newSPA := func(pin *autoscalingv1.ProfilePin, annotation string) *autoscalingv1.ScheduledPodAutoscaler {
+		spa := &autoscalingv1.ScheduledPodAutoscaler{
+			Spec: autoscalingv1.ScheduledPodAutoscalerSpec{
+				Profiles: []autoscalingv1.CapacityProfile{{Name: "normal", Value: 4}, {Name: "peak", Value: 20}},
+				ScaleUp:  autoscalingv1.ScaleSpec{Profile: "normal"},
+				Pin:      pin,
+			},
+		}
+		if annotation != "" {
+			spa.Annotations = map[string]string{autoscalingv1.PinAnnotation: annotation}
+		}
+		return spa
+	}
+
+	DescribeTable("resolveProfile picks a pin that hasn't expired over the step's profile",
+		func(pin *autoscalingv1.ProfilePin, annotation string, expectedProfile string, expectedReason string, expectedHoldOff time.Duration) {
+			spa := newSPA(pin, annotation)
+
+			profile, reason, holdOff, err := resolveProfile(spa, &spa.Spec.ScaleUp, "scaleUp", now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(profile.Name).To(Equal(expectedProfile))
+			Expect(reason).To(HavePrefix(expectedReason))
+			Expect(holdOff).To(Equal(expectedHoldOff))
+		},
+		Entry("the step's profile without a pin", nil, "", "normal", "selected by the scaleUp step", time.Duration(0)),
+		Entry("a pin without a duration", &autoscalingv1.ProfilePin{Profile: "peak"}, "", "peak", "pinned by spec.pin", time.Duration(0)),
+		Entry("a pin with a duration - until it expires", &autoscalingv1.ProfilePin{Profile: "peak", Duration: &metav1.Duration{Duration: time.Hour}}, "", "peak", "pinned by spec.pin until", time.Hour),
+		Entry("the annotation over spec.pin", &autoscalingv1.ProfilePin{Profile: "normal"}, "peak/30m", "peak", "pinned by annotation until", 30*time.Minute),
+	)
+
+	It("counts a pin's duration from when it is first observed - and falls back to the step's profile once it expires", func() {
+		spa := newSPA(nil, "peak/1h")
+
+		profile, _, holdOff, err := resolveProfile(spa, &spa.Spec.ScaleUp, "scaleUp", now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(profile.Name).To(Equal("peak"))
+		Expect(holdOff).To(Equal(time.Hour))
+		Expect(spa.Status.PinExpirationTime.Time).To(BeTemporally("==", now.Add(time.Hour)))
+
+		// later reconciles count down the same pin:
+		profile, _, holdOff, err = resolveProfile(spa, &spa.Spec.ScaleUp, "scaleUp", now.Add(45*time.Minute))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(profile.Name).To(Equal("peak"))
+		Expect(holdOff).To(Equal(15 * time.Minute))
+
+		profile, reason, holdOff, err := resolveProfile(spa, &spa.Spec.ScaleUp, "scaleUp", now.Add(time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(profile.Name).To(Equal("normal"))
+		Expect(reason).To(ContainSubstring("expired at"))
+		Expect(holdOff).To(BeZero())
+	})
+
+	It("restarts the duration of a changed pin", func() {
+		spa := newSPA(nil, "peak/1h")
+		_, _, _, err := resolveProfile(spa, &spa.Spec.ScaleUp, "scaleUp", now)
+		Expect(err).NotTo(HaveOccurred())
+
+		spa.Annotations[autoscalingv1.PinAnnotation] = "peak/2h"
+		_, _, holdOff, err := resolveProfile(spa, &spa.Spec.ScaleUp, "scaleUp", now.Add(90*time.Minute))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(holdOff).To(Equal(2 * time.Hour))
+	})
+
+	It("forgets an expired pin once it is removed", func() {
+		spa := newSPA(nil, "peak/1h")
+		_, _, _, err := resolveProfile(spa, &spa.Spec.ScaleUp, "scaleUp", now)
+		Expect(err).NotTo(HaveOccurred())
+
+		delete(spa.Annotations, autoscalingv1.PinAnnotation)
+		profile, _, _, err := resolveProfile(spa, &spa.Spec.ScaleUp, "scaleUp", now.Add(2*time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(profile.Name).To(Equal("normal"))
+		Expect(spa.Status.ObservedPin).To(BeEmpty())
+		Expect(spa.Status.PinExpirationTime).To(BeNil())
+	})
+
+	It("rejects pins of unknown profiles and malformed annotations", func() {
+		spa := newSPA(&autoscalingv1.ProfilePin{Profile: "holiday"}, "")
+		_, _, _, err := resolveProfile(spa, &spa.Spec.ScaleUp, "scaleUp", now)
+		Expect(err).To(MatchError(ContainSubstring(`pinned profile "holiday"`)))
+
+		spa = newSPA(nil, "peak/soon")
+		_, _, _, err = resolveProfile(spa, &spa.Spec.ScaleUp, "scaleUp", now)
+		Expect(err).To(MatchError(ContainSubstring("invalid duration")))
+	})
+})
@@ -0,0 +1,238 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+// scaleUpTimeToReady observes how long the pods of scale-ups took to become ready:
+var scaleUpTimeToReady = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "spa_scale_up_time_to_ready_seconds",
+	Help:    "Time from a scheduled scale-up until the scheduled replicas were ready.",
+	Buckets: prometheus.ExponentialBuckets(5, 2, 10),
+}, []string{"namespace", "name", "target"})
+
+func init() {
+	metrics.Registry.MustRegister(scaleUpTimeToReady)
+}
+
+// timeToReadySeries remembers the target label of the scaleUpTimeToReady series of every SPA (by target name), so
+// the series of targets and SPAs that are gone can be deleted:
+var timeToReadySeries = struct {
+	sync.Mutex
+	targets map[types.NamespacedName]map[string]string
+}{targets: map[types.NamespacedName]map[string]string{}}
+
+// observeTimeToReady records the time to ready of a scale-up of the target of the SPA.
+func observeTimeToReady(spa types.NamespacedName, target *scaleTarget, elapsed time.Duration) {
+	timeToReadySeries.Lock()
+	defer timeToReadySeries.Unlock()
+
+	if timeToReadySeries.targets[spa] == nil {
+		timeToReadySeries.targets[spa] = map[string]string{}
+	}
+	timeToReadySeries.targets[spa][target.key.Name] = target.String()
+	scaleUpTimeToReady.WithLabelValues(spa.Namespace, spa.Name, target.String()).Observe(elapsed.Seconds())
+}
+
+// retainTimeToReady deletes the scaleUpTimeToReady series of the SPA for every target but the named ones - all of
+// them if there are none (e.g. once the SPA is deleted).
+func retainTimeToReady(spa types.NamespacedName, names ...string) {
+	timeToReadySeries.Lock()
+	defer timeToReadySeries.Unlock()
+
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+	for name, target := range timeToReadySeries.targets[spa] {
+		if !keep[name] {
+			scaleUpTimeToReady.DeleteLabelValues(spa.Namespace, spa.Name, target)
+			delete(timeToReadySeries.targets[spa], name)
+		}
+	}
+	if len(timeToReadySeries.targets[spa]) == 0 {
+		delete(timeToReadySeries.targets, spa)
+	}
+}
+
+// forgetTimeToReady deletes the scaleUpTimeToReady series of the SPA for the named target.
+func forgetTimeToReady(spa types.NamespacedName, name string) {
+	timeToReadySeries.Lock()
+	defer timeToReadySeries.Unlock()
+
+	if target, ok := timeToReadySeries.targets[spa][name]; ok {
+		scaleUpTimeToReady.DeleteLabelValues(spa.Namespace, spa.Name, target)
+		delete(timeToReadySeries.targets[spa], name)
+	}
+	if len(timeToReadySeries.targets[spa]) == 0 {
+		delete(timeToReadySeries.targets, spa)
+	}
+}
+
+// stopReadinessTracking stops following the last scale-up of the target. A Degraded condition is reset - the missed
+// deadline no longer applies to the replicas the target runs with now.
+func stopReadinessTracking(status *autoscalingv1.TargetStatus, reason string, message string) {
+	status.ScaleUpTime, status.ExpectedReadyReplicas = nil, nil
+
+	if meta.FindStatusCondition(status.Conditions, autoscalingv1.ConditionDegraded) == nil {
+		return
+	}
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    autoscalingv1.ConditionDegraded,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// cachedWorkloadKind tells whether workloads of the kind are read through the cache - only Deployments and StatefulSets
+// are: the role grants list and watch on them. Other kinds (resources referenced by apiVersion/kind, or scaled by an
+// HPA) are only accessed through their /scale subresource - a cached read would start an informer that never syncs.
+func cachedWorkloadKind(gvk schema.GroupVersionKind) bool {
+	return gvk.GroupKind() == deploymentGVK.GroupKind() || gvk.GroupKind() == statefulSetGVK.GroupKind()
+}
+
+// readyReplicas returns the ready replicas of the workload running the target's pods - the target itself, or the
+// resource scaled by an HPA. It returns nil if the workload doesn't report ready replicas (e.g. for ScaledObjects)
+// or isn't a Deployment or StatefulSet (see cachedWorkloadKind).
+func (r *ScheduledPodAutoscalerReconciler) readyReplicas(ctx context.Context, target *scaleTarget) (*int32, error) {
+	workload := target
+	switch target.resourceType {
+	case "hpa", "hpaOperator":
+		scaled, err := r.hpaScaleTarget(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		workload = scaled
+	case "scaledObject":
+		return nil, nil
+	}
+	if !cachedWorkloadKind(workload.gvk) {
+		return nil, nil
+	}
+
+	// re-read the workload - it was just scaled:
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(workload.gvk)
+	if err := r.Get(ctx, workload.key, object); err != nil {
+		return nil, err
+	}
+
+	ready, found, err := unstructured.NestedInt64(object.Object, "status", "readyReplicas")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		// workloads leave readyReplicas out while none are ready - but only once they report a status at all:
+		if _, hasStatus, _ := unstructured.NestedMap(object.Object, "status"); !hasStatus {
+			return nil, nil
+		}
+	}
+
+	replicas := int32(ready)
+	return &replicas, nil
+}
+
+// startReadinessTracking starts following a scale-up of the target to the given replicas - or stops following
+// an earlier one if the target was scaled down since.
+func startReadinessTracking(status *autoscalingv1.TargetStatus, previous *int32, applied int32, now time.Time) {
+	if previous != nil && applied <= *previous {
+		stopReadinessTracking(status, "ScaleUpSuperseded", fmt.Sprintf("scaled to %d replicas since the last scale-up", applied))
+		return
+	}
+	status.ScaleUpTime = &metav1.Time{Time: now}
+	status.ExpectedReadyReplicas = &applied
+}
+
+// trackReadiness follows the last scale-up of the target until the expected replicas are ready - recording the time
+// to ready and setting the Degraded condition if that takes longer than the deadline (nil: no deadline). It returns
+// the time left until the deadline, if the scale-up is still within it.
+func (r *ScheduledPodAutoscalerReconciler) trackReadiness(ctx context.Context, spa *autoscalingv1.ScheduledPodAutoscaler, eventObject runtime.Object, target *scaleTarget, status *autoscalingv1.TargetStatus, deadline *metav1.Duration, now time.Time) (time.Duration, error) {
+	if status.ScaleUpTime == nil || status.ExpectedReadyReplicas == nil {
+		return 0, nil
+	}
+
+	ready, err := r.readyReplicas(ctx, target)
+	if err != nil {
+		return 0, err
+	}
+
+	// readiness can't be told for this target - nothing to track:
+	if ready == nil {
+		stopReadinessTracking(status, "ReadinessUnknown", fmt.Sprintf("%s doesn't report ready replicas", target))
+		return 0, nil
+	}
+
+	elapsed := now.Sub(status.ScaleUpTime.Time)
+	degraded := meta.FindStatusCondition(status.Conditions, autoscalingv1.ConditionDegraded)
+
+	if *ready >= *status.ExpectedReadyReplicas {
+		observeTimeToReady(types.NamespacedName{Namespace: spa.Namespace, Name: spa.Name}, target, elapsed)
+		recordTimeToReady(status, elapsed)
+
+		message := fmt.Sprintf("%d replicas of %s ready after %s", *ready, target, elapsed.Round(time.Second))
+		if degraded != nil && degraded.Status == metav1.ConditionTrue {
+			r.Recorder.Event(eventObject, corev1.EventTypeNormal, "Recovered", message)
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    autoscalingv1.ConditionDegraded,
+			Status:  metav1.ConditionFalse,
+			Reason:  "ScheduledReplicasReady",
+			Message: message,
+		})
+
+		status.ScaleUpTime, status.ExpectedReadyReplicas = nil, nil
+		return 0, nil
+	}
+
+	if deadline == nil || deadline.Duration <= 0 {
+		return 0, nil
+	}
+	if elapsed < deadline.Duration {
+		return deadline.Duration - elapsed, nil
+	}
+
+	message := fmt.Sprintf("only %d of %d replicas of %s ready within readinessDeadline of %s",
+		*ready, *status.ExpectedReadyReplicas, target, deadline.Duration)
+	if degraded == nil || degraded.Status != metav1.ConditionTrue {
+		r.Recorder.Event(eventObject, corev1.EventTypeWarning, autoscalingv1.ConditionDegraded, message)
+	}
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    autoscalingv1.ConditionDegraded,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ReadinessDeadlineExceeded",
+		Message: message,
+	})
+	return 0, nil
+}
@@ -0,0 +1,126 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var _ = Describe("Readiness tracking", func() {
+	int32Ptr := func(i int32) *int32 { return &i }
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+
+	degradedStatus := func() *autoscalingv1.TargetStatus {
+		status := &autoscalingv1.TargetStatus{
+			ScaleUpTime:           &metav1.Time{Time: now.Add(-time.Hour)},
+			ExpectedReadyReplicas: int32Ptr(10),
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:   autoscalingv1.ConditionDegraded,
+			Status: metav1.ConditionTrue,
+			Reason: "ReadinessDeadlineExceeded",
+		})
+		return status
+	}
+
+	It("follows a scale-up", func() {
+		status := &autoscalingv1.TargetStatus{}
+		startReadinessTracking(status, int32Ptr(3), 10, now)
+
+		Expect(status.ScaleUpTime.Time).To(Equal(now))
+		Expect(*status.ExpectedReadyReplicas).To(Equal(int32(10)))
+		Expect(status.Conditions).To(BeEmpty())
+	})
+
+	It("follows the first scale of a target without replicas", func() {
+		status := &autoscalingv1.TargetStatus{}
+		startReadinessTracking(status, nil, 4, now)
+
+		Expect(status.ScaleUpTime).NotTo(BeNil())
+		Expect(*status.ExpectedReadyReplicas).To(Equal(int32(4)))
+	})
+
+	It("stops following a scale-up superseded by a scale-down - resetting Degraded", func() {
+		status := degradedStatus()
+		startReadinessTracking(status, int32Ptr(10), 3, now)
+
+		Expect(status.ScaleUpTime).To(BeNil())
+		Expect(status.ExpectedReadyReplicas).To(BeNil())
+
+		degraded := meta.FindStatusCondition(status.Conditions, autoscalingv1.ConditionDegraded)
+		Expect(degraded.Status).To(Equal(metav1.ConditionFalse))
+		Expect(degraded.Reason).To(Equal("ScaleUpSuperseded"))
+	})
+
+	It("doesn't add a Degraded condition when there was none", func() {
+		status := &autoscalingv1.TargetStatus{ScaleUpTime: &metav1.Time{Time: now}, ExpectedReadyReplicas: int32Ptr(10)}
+		stopReadinessTracking(status, "ScaleUpSuperseded", "scaled down")
+
+		Expect(status.ScaleUpTime).To(BeNil())
+		Expect(status.Conditions).To(BeEmpty())
+	})
+
+	It("only reads Deployments and StatefulSets for their ready replicas", func() {
+		Expect(cachedWorkloadKind(deploymentGVK)).To(BeTrue())
+		Expect(cachedWorkloadKind(statefulSetGVK)).To(BeTrue())
+		Expect(cachedWorkloadKind(schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"})).To(BeFalse())
+		Expect(cachedWorkloadKind(scaledObjectGVK)).To(BeFalse())
+	})
+
+	It("deletes the time-to-ready series of targets that are gone", func() {
+		spa := types.NamespacedName{Namespace: "readiness-test", Name: "spa"}
+		web := &scaleTarget{gvk: deploymentGVK, key: client.ObjectKey{Namespace: spa.Namespace, Name: "web"}}
+		api := &scaleTarget{gvk: deploymentGVK, key: client.ObjectKey{Namespace: spa.Namespace, Name: "api"}}
+		before := testutil.CollectAndCount(scaleUpTimeToReady)
+
+		observeTimeToReady(spa, web, time.Minute)
+		observeTimeToReady(spa, api, time.Minute)
+		Expect(testutil.CollectAndCount(scaleUpTimeToReady)).To(Equal(before + 2))
+
+		retainTimeToReady(spa, "web")
+		Expect(testutil.CollectAndCount(scaleUpTimeToReady)).To(Equal(before + 1))
+		Expect(timeToReadySeries.targets[spa]).To(Equal(map[string]string{"web": "deployment web"}))
+
+		forgetTimeToReady(spa, "web")
+		Expect(testutil.CollectAndCount(scaleUpTimeToReady)).To(Equal(before))
+		Expect(timeToReadySeries.targets).NotTo(HaveKey(spa))
+	})
+
+	It("deletes every time-to-ready series of a deleted SPA", func() {
+		spa := types.NamespacedName{Namespace: "readiness-test", Name: "deleted"}
+		before := testutil.CollectAndCount(scaleUpTimeToReady)
+
+		for _, name := range []string{"a", "b", "c"} {
+			observeTimeToReady(spa, &scaleTarget{gvk: statefulSetGVK, key: client.ObjectKey{Namespace: spa.Namespace, Name: name}}, time.Second)
+		}
+		retainTimeToReady(spa)
+
+		Expect(testutil.CollectAndCount(scaleUpTimeToReady)).To(Equal(before))
+		Expect(timeToReadySeries.targets).NotTo(HaveKey(spa))
+	})
+})
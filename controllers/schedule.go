@@ -0,0 +1,182 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+// templateRefIndex indexes SPAs by the schedule template they reference - as <kind>/<name>:
+var templateRefIndex = "spec.templateRef"
+
+// templateRefKey returns the templateRefIndex value of an SPA - "" if it doesn't reference a template.
+func templateRefKey(spa *autoscalingv1.ScheduledPodAutoscaler) string {
+	if spa.Spec.TemplateRef == nil {
+		return ""
+	}
+
+	kind := spa.Spec.TemplateRef.Kind
+	if kind == "" {
+		kind = autoscalingv1.ScheduleTemplateKind
+	}
+	return kind + "/" + spa.Spec.TemplateRef.Name
+}
+
+// applyScheduleTemplate fills in the SPA's spec from the template it references: times and calendar always come from
+// the template, values (or profiles or links, maxReplicas and hpaProfile) only where the SPA doesn't set them.
+func (r *ScheduledPodAutoscalerReconciler) applyScheduleTemplate(ctx context.Context, spa *autoscalingv1.ScheduledPodAutoscaler) error {
+	var template autoscalingv1.ScheduleTemplateSpec
+
+	if spa.Spec.TemplateRef.Kind == autoscalingv1.ClusterScheduleTemplateKind {
+		var clusterScheduleTemplate autoscalingv1.ClusterScheduleTemplate
+		if err := r.Get(ctx, types.NamespacedName{Name: spa.Spec.TemplateRef.Name}, &clusterScheduleTemplate); err != nil {
+			return err
+		}
+		template = clusterScheduleTemplate.Spec
+	} else {
+		var scheduleTemplate autoscalingv1.ScheduleTemplate
+		if err := r.Get(ctx, types.NamespacedName{Name: spa.Spec.TemplateRef.Name, Namespace: spa.Namespace}, &scheduleTemplate); err != nil {
+			return err
+		}
+		template = scheduleTemplate.Spec
+	}
+
+	spa.Spec.ScheduleCalendar = template.ScheduleCalendar
+	mergeScaleSpec(&spa.Spec.ScaleUp, &template.ScaleUp)
+	mergeScaleSpec(&spa.Spec.ScaleDown, &template.ScaleDown)
+	return nil
+}
+
+// mergeScaleSpec takes the time of a template step - and the values the SPA's step doesn't set.
+func mergeScaleSpec(step *autoscalingv1.ScaleSpec, template *autoscalingv1.ScaleSpec) {
+	step.Time = template.Time
+
+	if step.Value == nil && step.Profile == "" && step.Link == nil {
+		step.Value = template.Value
+		step.Profile = template.Profile
+		step.Link = template.Link
+	}
+	if step.MaxReplicas == nil {
+		step.MaxReplicas = template.MaxReplicas
+	}
+	if step.HPAProfile == nil {
+		step.HPAProfile = template.HPAProfile
+	}
+}
+
+// scheduleLocation returns the time zone of the schedule - the controller's local time zone if unset.
+func scheduleLocation(calendar autoscalingv1.ScheduleCalendar) (*time.Location, error) {
+	if calendar.TimeZone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(calendar.TimeZone)
+}
+
+// scaleUpDay checks whether the scaleUp step starts on the day - i.e. it is one of the days and no holiday.
+func scaleUpDay(calendar autoscalingv1.ScheduleCalendar, day time.Time) bool {
+	for _, holiday := range calendar.Holidays {
+		if holiday == day.Format(autoscalingv1.HolidayLayout) {
+			return false
+		}
+	}
+
+	if len(calendar.Days) == 0 {
+		return true
+	}
+	for _, scheduleDay := range calendar.Days {
+		if string(scheduleDay) == day.Weekday().String() {
+			return true
+		}
+	}
+	return false
+}
+
+// activeScheduleStep works out which of two daily steps is active at the given time - "scaleUp" or "scaleDown" - along
+// with when it started. The scaleUp step starts lead before its time (possibly on the evening before), and only on the
+// days of the calendar (see scaleUpDay) - judged by the day of its time, not of its lead-time start.
+func activeScheduleStep(calendar autoscalingv1.ScheduleCalendar, scaleUpTime string, scaleDownTime string, lead time.Duration, now time.Time) (string, time.Time, error) {
+	location, err := scheduleLocation(calendar)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now = now.In(location)
+
+	var step string
+	var start time.Time
+	for _, candidate := range []struct {
+		name string
+		time string
+		lead time.Duration
+	}{{"scaleUp", scaleUpTime, lead}, {"scaleDown", scaleDownTime, 0}} {
+		clock, err := time.Parse(time.Kitchen, candidate.time)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+
+		// the latest start of the step that isn't in the future - going back as far as days without a scaleUp go
+		// (starting with tomorrow's, which the lead time may have moved to today):
+		for daysAgo := -1; daysAgo <= 7+len(calendar.Holidays); daysAgo++ {
+			day := now.AddDate(0, 0, -daysAgo)
+			stepTime := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, location)
+			candidateStart := stepTime.Add(-candidate.lead)
+
+			if candidateStart.After(now) || (candidate.name == "scaleUp" && !scaleUpDay(calendar, stepTime)) {
+				continue
+			}
+			if candidateStart.After(start) {
+				step, start = candidate.name, candidateStart
+			}
+			break
+		}
+	}
+
+	if step == "" {
+		return "", time.Time{}, fmt.Errorf("no scale step started in the past week")
+	}
+	return step, start, nil
+}
+
+// referencingScheduledPodAutoscalers maps a changed schedule template to the SPAs referencing it - found through templateRefIndex.
+func (r *ScheduledPodAutoscalerReconciler) referencingScheduledPodAutoscalers(kind string) func(obj client.Object) []reconcile.Request {
+	return func(obj client.Object) []reconcile.Request {
+		var scheduledPodAutoscalers autoscalingv1.ScheduledPodAutoscalerList
+
+		opts := []client.ListOption{client.MatchingFields{templateRefIndex: kind + "/" + obj.GetName()}}
+		if kind == autoscalingv1.ScheduleTemplateKind {
+			opts = append(opts, client.InNamespace(obj.GetNamespace()))
+		}
+
+		if err := r.List(context.Background(), &scheduledPodAutoscalers, opts...); err != nil {
+			r.Log.Error(err, "unable to list ScheduledPodAutoscalers referencing template", "kind", kind, "name", obj.GetName())
+			return nil
+		}
+
+		requests := make([]reconcile.Request, 0, len(scheduledPodAutoscalers.Items))
+		for _, spa := range scheduledPodAutoscalers.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: spa.Name, Namespace: spa.Namespace}})
+		}
+		return requests
+	}
+}
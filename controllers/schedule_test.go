@@ -0,0 +1,87 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var _ = Describe("Schedules", func() {
+	// Monday, 19 October 2026 - in UTC, the time zone of every calendar below:
+	at := func(day int, hour int, min int) time.Time {
+		return time.Date(2026, time.October, day, hour, min, 0, 0, time.UTC)
+	}
+	weekdays := []autoscalingv1.Day{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday"}
+
+	DescribeTable("activeScheduleStep works out the active step and its start",
+		func(calendar autoscalingv1.ScheduleCalendar, scaleUpTime string, scaleDownTime string, now time.Time, expectedStep string, expectedStart time.Time) {
+			calendar.TimeZone = "UTC"
+			step, start, err := activeScheduleStep(calendar, scaleUpTime, scaleDownTime, 0, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(step).To(Equal(expectedStep))
+			Expect(start).To(BeTemporally("==", expectedStart))
+		},
+		Entry("scaleUp between the two times", autoscalingv1.ScheduleCalendar{}, "8:00AM", "7:00PM", at(19, 12, 0), "scaleUp", at(19, 8, 0)),
+		Entry("scaleDown after both times", autoscalingv1.ScheduleCalendar{}, "8:00AM", "7:00PM", at(19, 21, 0), "scaleDown", at(19, 19, 0)),
+		Entry("yesterday's scaleDown before both times", autoscalingv1.ScheduleCalendar{}, "8:00AM", "7:00PM", at(19, 6, 0), "scaleDown", at(18, 19, 0)),
+		Entry("right at scaleUp.time", autoscalingv1.ScheduleCalendar{}, "8:00AM", "7:00PM", at(19, 8, 0), "scaleUp", at(19, 8, 0)),
+		Entry("a night shift - scaleUp after scaleDown", autoscalingv1.ScheduleCalendar{}, "10:00PM", "6:00AM", at(19, 2, 0), "scaleUp", at(18, 22, 0)),
+		Entry("a night shift during the day", autoscalingv1.ScheduleCalendar{}, "10:00PM", "6:00AM", at(19, 12, 0), "scaleDown", at(19, 6, 0)),
+		Entry("no scaleUp on a day that isn't one of the days", autoscalingv1.ScheduleCalendar{Days: weekdays}, "8:00AM", "7:00PM", at(18, 12, 0), "scaleDown", at(17, 19, 0)),
+		Entry("scaleUp on one of the days", autoscalingv1.ScheduleCalendar{Days: weekdays}, "8:00AM", "7:00PM", at(19, 12, 0), "scaleUp", at(19, 8, 0)),
+		Entry("no scaleUp on a holiday", autoscalingv1.ScheduleCalendar{Holidays: []string{"2026-10-19"}}, "8:00AM", "7:00PM", at(19, 12, 0), "scaleDown", at(18, 19, 0)),
+		Entry("a night shift starting on a holiday carries on past midnight", autoscalingv1.ScheduleCalendar{Holidays: []string{"2026-10-18"}}, "10:00PM", "6:00AM", at(19, 2, 0), "scaleDown", at(18, 6, 0)),
+	)
+
+	DescribeTable("activeScheduleStep starts the scaleUp step ahead by the lead time",
+		func(calendar autoscalingv1.ScheduleCalendar, scaleUpTime string, lead time.Duration, now time.Time, expectedStep string, expectedStart time.Time) {
+			calendar.TimeZone = "UTC"
+			step, start, err := activeScheduleStep(calendar, scaleUpTime, "7:00PM", lead, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(step).To(Equal(expectedStep))
+			Expect(start).To(BeTemporally("==", expectedStart))
+		},
+		Entry("within the lead time", autoscalingv1.ScheduleCalendar{}, "8:00AM", 30*time.Minute, at(19, 7, 45), "scaleUp", at(19, 7, 30)),
+		Entry("before the lead time", autoscalingv1.ScheduleCalendar{}, "8:00AM", 30*time.Minute, at(19, 7, 15), "scaleDown", at(18, 19, 0)),
+		Entry("a lead time crossing midnight", autoscalingv1.ScheduleCalendar{}, "12:30AM", time.Hour, at(18, 23, 45), "scaleUp", at(18, 23, 30)),
+		Entry("a lead time crossing midnight - after scaleUp.time", autoscalingv1.ScheduleCalendar{}, "12:30AM", time.Hour, at(19, 1, 0), "scaleUp", at(18, 23, 30)),
+		Entry("a lead time crossing midnight into one of the days", autoscalingv1.ScheduleCalendar{Days: weekdays}, "12:30AM", time.Hour, at(18, 23, 45), "scaleUp", at(18, 23, 30)),
+		Entry("a lead time crossing midnight into a day that isn't one of the days", autoscalingv1.ScheduleCalendar{Days: weekdays}, "12:30AM", time.Hour, at(16, 23, 45), "scaleDown", at(16, 19, 0)),
+		Entry("a lead time crossing midnight into a holiday", autoscalingv1.ScheduleCalendar{Holidays: []string{"2026-10-19"}}, "12:30AM", time.Hour, at(18, 23, 45), "scaleDown", at(18, 19, 0)),
+	)
+
+	It("works out the step in the time zone of the calendar", func() {
+		calendar := autoscalingv1.ScheduleCalendar{TimeZone: "Asia/Tokyo"}
+
+		// 12:00 UTC is 21:00 in Tokyo - after scaleDown.time:
+		step, start, err := activeScheduleStep(calendar, "8:00AM", "7:00PM", 0, at(19, 12, 0))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(step).To(Equal("scaleDown"))
+		Expect(start).To(BeTemporally("==", at(19, 10, 0)))
+	})
+
+	It("rejects times that aren't of the form 8:15AM", func() {
+		_, _, err := activeScheduleStep(autoscalingv1.ScheduleCalendar{}, "8:15", "7:00PM", 0, at(19, 12, 0))
+		Expect(err).To(HaveOccurred())
+	})
+})
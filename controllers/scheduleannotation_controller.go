@@ -0,0 +1,366 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var (
+	// scheduleAnnotation declares the schedule of a workload, e.g. "8:15AM=20,10:00PM=5":
+	scheduleAnnotation = "spa.sarmadabualkaz.io/schedule"
+
+	// effectiveScheduleAnnotation reports the schedule as understood by the controller:
+	effectiveScheduleAnnotation = "spa.sarmadabualkaz.io/effective-schedule"
+
+	// scheduleErrorAnnotation reports why the schedule can't be applied - unset while it is applied:
+	scheduleErrorAnnotation = "spa.sarmadabualkaz.io/schedule-error"
+
+	// scheduleStatusAnnotation summarizes the next step of the schedule - and keeps the little of an SPA's status the
+	// schedule needs between reconciles (see scheduleState):
+	scheduleStatusAnnotation = "spa.sarmadabualkaz.io/schedule-status"
+)
+
+// scheduleState is what the schedule-status annotation holds. It only changes when a step starts or the workload is
+// scaled - workload annotations are copied onto every ReplicaSet, so nothing that changes on every reconcile is kept.
+type scheduleState struct {
+	// the step after the active one, when it starts and the replicas it scales to:
+	NextStep      string      `json:"nextStep,omitempty"`
+	NextStepTime  metav1.Time `json:"nextStepTime,omitempty"`
+	NextStepValue int32       `json:"nextStepValue,omitempty"`
+
+	// what the schedule needs of the SPA's status between reconciles:
+	LastScheduleTime       *metav1.Time `json:"lastScheduleTime,omitempty"`
+	LastAppliedReplicas    *int32       `json:"lastAppliedReplicas,omitempty"`
+	OriginalMaxReplicas    *int32       `json:"originalMaxReplicas,omitempty"`
+	OriginalTargetReplicas *int32       `json:"originalTargetReplicas,omitempty"`
+	ScaleUpTime            *metav1.Time `json:"scaleUpTime,omitempty"`
+	ExpectedReadyReplicas  *int32       `json:"expectedReadyReplicas,omitempty"`
+}
+
+// ScheduleAnnotationReconciler applies the schedule declared by the spa.sarmadabualkaz.io/schedule annotation of
+// Deployments (or HPAs) - evaluated like the schedule of an SPA, without an SPA object.
+type ScheduleAnnotationReconciler struct {
+	*ScheduledPodAutoscalerReconciler
+
+	// ResourceType of the annotated workloads - deployment or hpa:
+	ResourceType string
+
+	gvk schema.GroupVersionKind
+}
+
+func (r *ScheduleAnnotationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues(r.ResourceType, req.NamespacedName)
+
+	// 1. Load the annotated workload:
+	workload := &unstructured.Unstructured{}
+	workload.SetGroupVersionKind(r.gvk)
+
+	if err := r.Get(ctx, req.NamespacedName, workload); err != nil {
+		log.Error(err, "unable to fetch annotated resource")
+		// the time-to-ready series of the schedule of a deleted workload are dropped:
+		if apierrors.IsNotFound(err) {
+			forgetTimeToReady(req.NamespacedName, req.Name)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	schedule, ok := workload.GetAnnotations()[scheduleAnnotation]
+	if !ok {
+		// the schedule was removed - so are the annotations reporting on it (and its time-to-ready series):
+		forgetTimeToReady(req.NamespacedName, req.Name)
+		return ctrl.Result{}, r.setScheduleAnnotations(ctx, workload, map[string]*string{
+			effectiveScheduleAnnotation: nil,
+			scheduleErrorAnnotation:     nil,
+			scheduleStatusAnnotation:    nil,
+		})
+	}
+
+	// 2. Leave workloads scaled by an SPA (or a ScheduledScalingGroup) to it - reporting the overlap:
+	claims, err := r.scaleClaims(ctx, workload.GetNamespace(), "")
+	if err != nil {
+		log.Error(err, "unable to list SPAs and groups of the namespace")
+		return ctrl.Result{}, err
+	}
+
+	if claimedBy := claims[r.gvk.Kind+"/"+workload.GetName()]; len(claimedBy) > 0 {
+		message := fmt.Sprintf("schedule is ignored - %s %s is scaled by %s", strings.ToLower(r.gvk.Kind), workload.GetName(), strings.Join(claimedBy, ", "))
+		if workload.GetAnnotations()[scheduleErrorAnnotation] != message {
+			log.V(1).Info("Leaving workload to the SPA scaling it", "scaledBy", claimedBy)
+			r.Recorder.Eventf(workload, corev1.EventTypeWarning, "ScheduleOverlap", "%s", message)
+		}
+		return ctrl.Result{RequeueAfter: requeueRate(log)}, r.setScheduleAnnotations(ctx, workload, map[string]*string{
+			effectiveScheduleAnnotation: nil,
+			scheduleErrorAnnotation:     &message,
+		})
+	}
+
+	// 3. Build the SPA declared by the annotation - defaulted and validated like any other SPA:
+	scheduledPodAutoscaler, err := scheduleAnnotationSPA(workload, r.ResourceType, schedule)
+	if err == nil {
+		scheduledPodAutoscaler.Default()
+		err = scheduledPodAutoscaler.ValidateCreate()
+	}
+
+	if err != nil {
+		log.Error(err, "invalid schedule annotation", "schedule", schedule)
+
+		message := err.Error()
+		if workload.GetAnnotations()[scheduleErrorAnnotation] != message {
+			r.Recorder.Eventf(workload, corev1.EventTypeWarning, "InvalidSchedule", "unable to apply schedule %q: %s", schedule, message)
+		}
+		return ctrl.Result{}, r.setScheduleAnnotations(ctx, workload, map[string]*string{
+			effectiveScheduleAnnotation: nil,
+			scheduleErrorAnnotation:     &message,
+		})
+	}
+
+	// 4. Evaluate the schedule as for an SPA - reporting back through annotations and events of the workload:
+	result, err := r.reconcileSchedule(ctx, log, scheduledPodAutoscaler, workload, func() error {
+		state, err := newScheduleState(scheduledPodAutoscaler, time.Now())
+		if err != nil {
+			return err
+		}
+		status, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+
+		effective := fmt.Sprintf("scaleUp %s=%s, scaleDown %s=%s",
+			scheduledPodAutoscaler.Spec.ScaleUp.Time, scheduledPodAutoscaler.Spec.ScaleUp.Value.String(),
+			scheduledPodAutoscaler.Spec.ScaleDown.Time, scheduledPodAutoscaler.Spec.ScaleDown.Value.String())
+		statusValue := string(status)
+
+		return r.setScheduleAnnotations(ctx, workload, map[string]*string{
+			effectiveScheduleAnnotation: &effective,
+			scheduleErrorAnnotation:     nil,
+			scheduleStatusAnnotation:    &statusValue,
+		})
+	})
+
+	if err != nil {
+		message := err.Error()
+		if workload.GetAnnotations()[scheduleErrorAnnotation] != message {
+			r.Recorder.Eventf(workload, corev1.EventTypeWarning, "ScheduleFailed", "unable to apply schedule %q: %s", schedule, message)
+		}
+		if annotationErr := r.setScheduleAnnotations(ctx, workload, map[string]*string{scheduleErrorAnnotation: &message}); annotationErr != nil {
+			log.Error(annotationErr, "unable to report schedule error through annotation")
+		}
+	}
+	return result, err
+}
+
+// scheduleAnnotationSPA returns the SPA declared by the schedule annotation of a workload - along with the status
+// kept for it by a previous reconcile.
+func scheduleAnnotationSPA(workload *unstructured.Unstructured, resourceType string, schedule string) (*autoscalingv1.ScheduledPodAutoscaler, error) {
+	scaleUp, scaleDown, err := parseScheduleAnnotation(schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduledPodAutoscaler := &autoscalingv1.ScheduledPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: workload.GetName(), Namespace: workload.GetNamespace()},
+		Spec: autoscalingv1.ScheduledPodAutoscalerSpec{
+			Resource:  autoscalingv1.Resource{Name: workload.GetName(), Type: resourceType},
+			ScaleUp:   scaleUp,
+			ScaleDown: scaleDown,
+		},
+	}
+
+	if status, ok := workload.GetAnnotations()[scheduleStatusAnnotation]; ok {
+		var state scheduleState
+		if err := json.Unmarshal([]byte(status), &state); err != nil {
+			return nil, fmt.Errorf("unable to read annotation %s: %w", scheduleStatusAnnotation, err)
+		}
+
+		target := &scheduledPodAutoscaler.Status.TargetStatus
+		target.LastScheduleTime, target.LastAppliedReplicas = state.LastScheduleTime, state.LastAppliedReplicas
+		target.OriginalMaxReplicas, target.OriginalTargetReplicas = state.OriginalMaxReplicas, state.OriginalTargetReplicas
+		target.ScaleUpTime, target.ExpectedReadyReplicas = state.ScaleUpTime, state.ExpectedReadyReplicas
+	}
+	return scheduledPodAutoscaler, nil
+}
+
+// newScheduleState summarizes the next step of the SPA declared by a schedule annotation, along with the part of its
+// status kept between reconciles.
+func newScheduleState(spa *autoscalingv1.ScheduledPodAutoscaler, now time.Time) (*scheduleState, error) {
+	active, _, err := activeScheduleStep(spa.Spec.ScheduleCalendar, spa.Spec.ScaleUp.Time, spa.Spec.ScaleDown.Time, 0, now)
+	if err != nil {
+		return nil, err
+	}
+
+	next, nextStep := "scaleDown", spa.Spec.ScaleDown
+	if active == "scaleDown" {
+		next, nextStep = "scaleUp", spa.Spec.ScaleUp
+	}
+	nextTime, err := nextStepTime(spa.Spec.ScheduleCalendar, next, nextStep.Time, now)
+	if err != nil {
+		return nil, err
+	}
+
+	target := spa.Status.TargetStatus
+	return &scheduleState{
+		NextStep:               next,
+		NextStepTime:           metav1.Time{Time: nextTime},
+		NextStepValue:          nextStep.Value.IntVal,
+		LastScheduleTime:       target.LastScheduleTime,
+		LastAppliedReplicas:    target.LastAppliedReplicas,
+		OriginalMaxReplicas:    target.OriginalMaxReplicas,
+		OriginalTargetReplicas: target.OriginalTargetReplicas,
+		ScaleUpTime:            target.ScaleUpTime,
+		ExpectedReadyReplicas:  target.ExpectedReadyReplicas,
+	}, nil
+}
+
+// nextStepTime returns when the step next starts after now - the scaleUp step only on the days of the calendar.
+func nextStepTime(calendar autoscalingv1.ScheduleCalendar, step string, stepTime string, now time.Time) (time.Time, error) {
+	location, err := scheduleLocation(calendar)
+	if err != nil {
+		return time.Time{}, err
+	}
+	clock, err := time.Parse(time.Kitchen, stepTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	now = now.In(location)
+	for days := 0; days <= 8+len(calendar.Holidays); days++ {
+		day := now.AddDate(0, 0, days)
+		next := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, location)
+		if next.After(now) && (step != "scaleUp" || scaleUpDay(calendar, next)) {
+			return next, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no %s step in the coming week", step)
+}
+
+// parseScheduleAnnotation reads a schedule of the form "8:15AM=20,10:00PM=5" - the step with the higher value is scaleUp.
+func parseScheduleAnnotation(schedule string) (scaleUp autoscalingv1.ScaleSpec, scaleDown autoscalingv1.ScaleSpec, err error) {
+	steps := strings.Split(schedule, ",")
+	if len(steps) != 2 {
+		return scaleUp, scaleDown, fmt.Errorf("schedule must have exactly two steps of the form <time>=<value>, e.g. \"8:15AM=20,10:00PM=5\"")
+	}
+
+	var specs [2]autoscalingv1.ScaleSpec
+	for i, step := range steps {
+		parts := strings.SplitN(step, "=", 2)
+		if len(parts) != 2 {
+			return scaleUp, scaleDown, fmt.Errorf("step %q is not of the form <time>=<value>", strings.TrimSpace(step))
+		}
+
+		value, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 32)
+		if err != nil {
+			return scaleUp, scaleDown, fmt.Errorf("value of step %q is not a number", strings.TrimSpace(step))
+		}
+
+		replicas := intstr.FromInt(int(value))
+		specs[i] = autoscalingv1.ScaleSpec{Time: strings.TrimSpace(parts[0]), Value: &replicas}
+	}
+
+	if specs[0].Value.IntVal < specs[1].Value.IntVal {
+		return specs[1], specs[0], nil
+	}
+	return specs[0], specs[1], nil
+}
+
+// setScheduleAnnotations sets the given annotations of the workload - removing the ones without a value.
+// The workload is only patched if any of them changes.
+func (r *ScheduleAnnotationReconciler) setScheduleAnnotations(ctx context.Context, workload *unstructured.Unstructured, annotations map[string]*string) error {
+	changed := false
+	for key, value := range annotations {
+		current, ok := workload.GetAnnotations()[key]
+		if (value == nil && ok) || (value != nil && (!ok || current != *value)) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	return r.patchResource(ctx, r.gvk, client.ObjectKeyFromObject(workload), func(u *unstructured.Unstructured) error {
+		for key, value := range annotations {
+			if value == nil {
+				unstructured.RemoveNestedField(u.Object, "metadata", "annotations", key)
+			} else if err := unstructured.SetNestedField(u.Object, *value, "metadata", "annotations", key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// hasScheduleAnnotations checks whether the workload declares a schedule - or still reports on a removed one.
+func hasScheduleAnnotations(obj client.Object) bool {
+	for _, key := range []string{scheduleAnnotation, effectiveScheduleAnnotation, scheduleErrorAnnotation, scheduleStatusAnnotation} {
+		if _, ok := obj.GetAnnotations()[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleChanged checks whether an update changed the schedule annotation.
+func scheduleChanged(e event.UpdateEvent) bool {
+	return e.ObjectOld.GetAnnotations()[scheduleAnnotation] != e.ObjectNew.GetAnnotations()[scheduleAnnotation]
+}
+
+func (r *ScheduleAnnotationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	switch r.ResourceType {
+	case "deployment":
+		r.gvk = deploymentGVK
+	case "hpa":
+		mapping, err := r.Mapper.RESTMapping(hpaGroupKind, hpaVersions...)
+		if err != nil {
+			return err
+		}
+		r.gvk = mapping.GroupVersionKind
+	default:
+		return fmt.Errorf("schedule annotations are only supported on deployments and HPAs - not %s", r.ResourceType)
+	}
+
+	workload := &unstructured.Unstructured{}
+	workload.SetGroupVersionKind(r.gvk)
+
+	// the annotations reporting on the schedule are written by this controller - only changes of the spec or the
+	// schedule itself are reconciled (so reporting doesn't trigger another reconcile):
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("scheduleannotation-"+r.ResourceType).
+		For(workload, builder.WithPredicates(
+			predicate.NewPredicateFuncs(hasScheduleAnnotations),
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.Funcs{UpdateFunc: scheduleChanged}),
+		)).
+		Complete(r)
+}
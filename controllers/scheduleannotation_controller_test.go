@@ -0,0 +1,129 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var _ = Describe("Schedule annotations", func() {
+	DescribeTable("parseScheduleAnnotation reads both steps - the one with the higher value as scaleUp",
+		func(schedule string, scaleUpTime string, scaleUpValue int, scaleDownTime string, scaleDownValue int) {
+			scaleUp, scaleDown, err := parseScheduleAnnotation(schedule)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(scaleUp.Time).To(Equal(scaleUpTime))
+			Expect(scaleUp.Value.IntValue()).To(Equal(scaleUpValue))
+			Expect(scaleDown.Time).To(Equal(scaleDownTime))
+			Expect(scaleDown.Value.IntValue()).To(Equal(scaleDownValue))
+		},
+		Entry("scaleUp first", "8:15AM=20,10:00PM=5", "8:15AM", 20, "10:00PM", 5),
+		Entry("scaleDown first", "10:00PM=5,8:15AM=20", "8:15AM", 20, "10:00PM", 5),
+		Entry("with spaces around times and values", " 8:15AM = 20 , 10:00PM = 5 ", "8:15AM", 20, "10:00PM", 5),
+		Entry("equal values - in the given order", "8:15AM=5,10:00PM=5", "8:15AM", 5, "10:00PM", 5),
+		Entry("a value of zero", "8:15AM=3,10:00PM=0", "8:15AM", 3, "10:00PM", 0),
+	)
+
+	DescribeTable("parseScheduleAnnotation rejects malformed schedules",
+		func(schedule string, message string) {
+			_, _, err := parseScheduleAnnotation(schedule)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("a single step", "8:15AM=20", "exactly two steps"),
+		Entry("three steps", "8:15AM=20,1:00PM=10,10:00PM=5", "exactly two steps"),
+		Entry("a step without a value", "8:15AM=20,10:00PM", `step "10:00PM" is not of the form`),
+		Entry("a value that isn't a number", "8:15AM=twenty,10:00PM=5", `value of step "8:15AM=twenty" is not a number`),
+		Entry("a percentage", "8:15AM=20%,10:00PM=5", "is not a number"),
+	)
+
+	DescribeTable("scheduleChanged only reports changes of the schedule annotation",
+		func(oldAnnotations map[string]string, newAnnotations map[string]string, expected bool) {
+			oldObject, newObject := &unstructured.Unstructured{}, &unstructured.Unstructured{}
+			oldObject.SetAnnotations(oldAnnotations)
+			newObject.SetAnnotations(newAnnotations)
+			Expect(scheduleChanged(event.UpdateEvent{ObjectOld: oldObject, ObjectNew: newObject})).To(Equal(expected))
+		},
+		Entry("a changed schedule", map[string]string{scheduleAnnotation: "8:15AM=20,10:00PM=5"}, map[string]string{scheduleAnnotation: "8:15AM=30,10:00PM=5"}, true),
+		Entry("a removed schedule", map[string]string{scheduleAnnotation: "8:15AM=20,10:00PM=5"}, map[string]string{}, true),
+		Entry("a changed status only", map[string]string{scheduleAnnotation: "8:15AM=20,10:00PM=5", scheduleStatusAnnotation: "{}"},
+			map[string]string{scheduleAnnotation: "8:15AM=20,10:00PM=5", scheduleStatusAnnotation: `{"lastScheduleTime":"2021-01-01T08:15:00Z"}`}, false),
+	)
+
+	DescribeTable("newScheduleState summarizes the step after the active one",
+		func(now time.Time, nextStep string, nextStepTime time.Time, nextStepValue int32) {
+			scaleUp, scaleDown, err := parseScheduleAnnotation("8:15AM=20,10:00PM=5")
+			Expect(err).NotTo(HaveOccurred())
+			spa := &autoscalingv1.ScheduledPodAutoscaler{Spec: autoscalingv1.ScheduledPodAutoscalerSpec{
+				ScaleUp: scaleUp, ScaleDown: scaleDown, ScheduleCalendar: autoscalingv1.ScheduleCalendar{TimeZone: "UTC"},
+			}}
+
+			state, err := newScheduleState(spa, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.NextStep).To(Equal(nextStep))
+			Expect(state.NextStepTime.Time).To(BeTemporally("==", nextStepTime))
+			Expect(state.NextStepValue).To(Equal(nextStepValue))
+		},
+		Entry("during scaleUp - scaleDown later the same day",
+			time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC), "scaleDown", time.Date(2021, 1, 4, 22, 0, 0, 0, time.UTC), int32(5)),
+		Entry("during scaleDown before midnight - scaleUp the next day",
+			time.Date(2021, 1, 4, 23, 0, 0, 0, time.UTC), "scaleUp", time.Date(2021, 1, 5, 8, 15, 0, 0, time.UTC), int32(20)),
+		Entry("during scaleDown after midnight - scaleUp the same day",
+			time.Date(2021, 1, 5, 6, 0, 0, 0, time.UTC), "scaleUp", time.Date(2021, 1, 5, 8, 15, 0, 0, time.UTC), int32(20)),
+	)
+
+	It("keeps the state the schedule needs - and nothing that changes every reconcile - across reconciles", func() {
+		scaleUp, scaleDown, err := parseScheduleAnnotation("8:15AM=20,10:00PM=5")
+		Expect(err).NotTo(HaveOccurred())
+		replicas, original := int32(20), int32(3)
+		scheduleTime := metav1.NewTime(time.Date(2021, 1, 4, 8, 15, 0, 0, time.UTC))
+
+		spa := &autoscalingv1.ScheduledPodAutoscaler{Spec: autoscalingv1.ScheduledPodAutoscalerSpec{
+			ScaleUp: scaleUp, ScaleDown: scaleDown, ScheduleCalendar: autoscalingv1.ScheduleCalendar{TimeZone: "UTC"},
+		}}
+		spa.Status.LastScheduleTime, spa.Status.LastAppliedReplicas, spa.Status.OriginalMaxReplicas = &scheduleTime, &replicas, &original
+		spa.Status.ContestedCount = 2
+		spa.Status.Conditions = []metav1.Condition{{Type: autoscalingv1.ConditionContested, Status: metav1.ConditionFalse}}
+
+		first, err := newScheduleState(spa, time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC))
+		Expect(err).NotTo(HaveOccurred())
+		later, err := newScheduleState(spa, time.Date(2021, 1, 4, 12, 10, 0, 0, time.UTC))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(later).To(Equal(first))
+
+		status, err := json.Marshal(first)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(status)).NotTo(ContainSubstring("contestedCount"))
+		Expect(string(status)).NotTo(ContainSubstring("conditions"))
+
+		workload := &unstructured.Unstructured{}
+		workload.SetAnnotations(map[string]string{scheduleStatusAnnotation: string(status)})
+		restored, err := scheduleAnnotationSPA(workload, "deployment", "8:15AM=20,10:00PM=5")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restored.Status.LastScheduleTime.Time).To(BeTemporally("==", scheduleTime.Time))
+		Expect(*restored.Status.LastAppliedReplicas).To(Equal(replicas))
+		Expect(*restored.Status.OriginalMaxReplicas).To(Equal(original))
+	})
+})
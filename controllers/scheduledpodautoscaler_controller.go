@@ -20,17 +20,27 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
-	appsv1 "k8s.io/api/apps/v1"
-	kautoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/scale"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
 )
@@ -38,17 +48,59 @@ import (
 // ScheduledPodAutoscalerReconciler reconciles a ScheduledPodAutoscaler object
 type ScheduledPodAutoscalerReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// APIReader reads straight from the API server - patches are built from it, as the cache may lag behind
+	// the last write (falls back to the Client if unset):
+	APIReader client.Reader
+
+	// Mapper and ScaleClient are used to read and write the /scale subresource of the scaled resources:
+	Mapper      meta.RESTMapper
+	ScaleClient scale.ScalesGetter
+
+	// PlaceholderImage is the image run by placeholder pods (defaults to registry.k8s.io/pause if unset):
+	PlaceholderImage string
 }
 
 // +kubebuilder:rbac:groups=autoscaling.spa.sarmadabualkaz.io,resources=scheduledpodautoscalers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=autoscaling.spa.sarmadabualkaz.io,resources=scheduledpodautoscalers/status,verbs=get;update;patch
-// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;update;patch
-// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=autoscaling.spa.sarmadabualkaz.io,resources=scheduletemplates;clusterscheduletemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=resourcequotas;nodes;pods,verbs=get;list;watch
+// pods are patched for their pod-deletion-cost - and placeholder pods are created and deleted:
+// +kubebuilder:rbac:groups="",resources=pods,verbs=create;patch;delete
+// deployments and statefulsets are only patched for their annotations (HPA-operator annotations, schedule annotations and
+// the original replicas of hibernated workloads) - their replicas are always written through */scale:
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=scheduling.k8s.io,resources=priorityclasses,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=*,resources=*/scale,verbs=get;update
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=keda.sh,resources=scaledobjects,verbs=get;list;watch;patch
 
 var (
 	scheduledTimeAnnotation = "spa.sarmadabualkaz.io/scheduled-at"
+
+	// fieldManager is recorded in managedFields for every write the controller makes:
+	fieldManager = "spa-controller"
+
+	deploymentGVK = schema.GroupVersionKind{
+		Group:   "apps",
+		Kind:    "Deployment",
+		Version: "v1",
+	}
+
+	hpaGroupKind = schema.GroupKind{
+		Group: "autoscaling",
+		Kind:  "HorizontalPodAutoscaler",
+	}
+
+	// hpaVersions are the HorizontalPodAutoscaler versions read and patched by the controller, most preferred first
+	// (autoscaling/v2beta2 for clusters that predate autoscaling/v2) - older versions would lose metrics and behavior:
+	hpaVersions = []string{"v2", "v2beta2"}
 )
 
 func (r *ScheduledPodAutoscalerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -58,82 +110,82 @@ func (r *ScheduledPodAutoscalerReconciler) Reconcile(ctx context.Context, req ct
 	var scheduledPodAutoscaler autoscalingv1.ScheduledPodAutoscaler
 	if err := r.Get(ctx, req.NamespacedName, &scheduledPodAutoscaler); err != nil {
 		log.Error(err, "unable to fetch ScheduledPodAutoscaler")
+		// the time-to-ready series of a deleted SPA are dropped:
+		if apierrors.IsNotFound(err) {
+			retainTimeToReady(req.NamespacedName)
+		}
 		// we'll ignore not-found errors, since they can't be fixed by an immediate
 		// requeue (we'll need to wait for a new notification), and we can get them
 		// on deleted requests.
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	// 2. Validate resource is one of the 3 main types 'scream back if its not :|':
-	var passedResourceType string
-	var passedResourceName string
-	var resourceType string
+	return r.reconcileSchedule(ctx, log, &scheduledPodAutoscaler, &scheduledPodAutoscaler, func() error {
+		return r.Status().Update(ctx, &scheduledPodAutoscaler)
+	})
+}
 
-	passedResourceType = scheduledPodAutoscaler.Spec.Resource.Type
-	passedResourceName = scheduledPodAutoscaler.Spec.Resource.Name
+// reconcileSchedule takes steps 2. to 12. for an SPA - which is not necessarily stored in the cluster (see
+// ScheduleAnnotationReconciler): events are recorded on eventObject, and updateStatus saves the SPA's status.
+func (r *ScheduledPodAutoscalerReconciler) reconcileSchedule(ctx context.Context, log logr.Logger, scheduledPodAutoscaler *autoscalingv1.ScheduledPodAutoscaler, eventObject runtime.Object, updateStatus func() error) (ctrl.Result, error) {
+	// 2. Validate resource is one of the 5 main types - or referenced by apiVersion/kind - 'scream back if its not :|':
+	passedResourceName := scheduledPodAutoscaler.Spec.Resource.Name
 
-	if (passedResourceType == "deployment") || (passedResourceType == "Deployment") {
-		resourceType = "deployment"
-	} else if (passedResourceType == "annotatedDeployment") || (passedResourceType == "AnnotatedDeployment") {
-		resourceType = "hpaOperator"
-	} else if (passedResourceType == "HPA") || (passedResourceType == "hpa") || (passedResourceType == "HorizontalPodAutoscaler") || (passedResourceType == "horizontalPodAutoscaler") {
-		resourceType = "hpa"
-	} else {
-		err := fmt.Errorf("unrecognizable resource.type %s ResourceType", passedResourceType)
+	resourceType, err := normalizedResourceType(scheduledPodAutoscaler)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	// 3. Get the respective resource (Deployment if resourceType = "deployment" or "hpaOperator"; HorizontalPodAutoscaler if resourceType = "hpaOperator"):
-	getResourceSpec := func(resourceName string, resourceType string) (deploymentSpec *appsv1.Deployment, hpaSpec *kautoscalingv1.HorizontalPodAutoscaler, err error) {
-		if (resourceType == "deployment") || (resourceType == "hpaOperator") {
-			deploymentSpec = &appsv1.Deployment{}
+	// 3. Get the respective resource (Deployment if resourceType = "deployment" or "hpaOperator"; StatefulSet if resourceType = "statefulset";
+	// HorizontalPodAutoscaler if resourceType = "hpa"; KEDA ScaledObject if resourceType = "scaledObject"; the /scale subresource of apiVersion/kind if resourceType = "scale"):
+	// - or every one of them matching spec.resource.selector:
+	var targets []*scaleTarget
 
-			u := &unstructured.Unstructured{}
-			u.SetGroupVersionKind(schema.GroupVersionKind{
-				Group:   "apps",
-				Kind:    "Deployment",
-				Version: "v1",
-			})
+	if resourceType == "hibernate" {
+		log.V(1).Info("Hibernate mode - every Deployment and StatefulSet of the namespace is scaled")
+	} else if selector := scheduledPodAutoscaler.Spec.Resource.Selector; selector != nil {
+		log.V(1).Info("Checking for resources matching selector:", "type", resourceType, "selector", metav1.FormatLabelSelector(selector))
 
-			err := r.Get(ctx, client.ObjectKey{Name: resourceName, Namespace: req.NamespacedName.Namespace}, u)
-			if err != nil {
-				return &appsv1.Deployment{}, &kautoscalingv1.HorizontalPodAutoscaler{}, err
-			}
-
-			runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), &deploymentSpec)
+		var err error
+		targets, err = r.listScaleTargets(ctx, resourceType, scheduledPodAutoscaler.Spec.Resource, scheduledPodAutoscaler.Namespace)
 
-			return deploymentSpec, &kautoscalingv1.HorizontalPodAutoscaler{}, nil
-		} else if resourceType == "hpa" {
-			hpaSpec = &kautoscalingv1.HorizontalPodAutoscaler{}
-
-			u := &unstructured.Unstructured{}
-			u.SetGroupVersionKind(schema.GroupVersionKind{
-				Group:   "autoscaling",
-				Kind:    "HorizontalPodAutoscaler",
-				Version: "v1",
-			})
-
-			err := r.Get(ctx, client.ObjectKey{Name: resourceName, Namespace: req.NamespacedName.Namespace}, u)
-			if err != nil {
-				return &appsv1.Deployment{}, &kautoscalingv1.HorizontalPodAutoscaler{}, err
-			}
+		if err != nil {
+			log.Error(err, "unable to list resources for", "selector", metav1.FormatLabelSelector(selector), "and resource type", resourceType)
+			return ctrl.Result{}, err
+		}
+	} else {
+		log.V(1).Info("Checking for resource:", "type", resourceType, "name", passedResourceName)
 
-			runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), &hpaSpec)
+		target, err := r.getScaleTarget(ctx, resourceType, scheduledPodAutoscaler.Spec.Resource, scheduledPodAutoscaler.Namespace)
 
-			return &appsv1.Deployment{}, hpaSpec, err
-		} else {
-			err := fmt.Errorf("unrecognizable resource.type %s ResourceType", resourceType)
-			return &appsv1.Deployment{}, &kautoscalingv1.HorizontalPodAutoscaler{}, err
+		if err != nil {
+			log.Error(err, "unable to find resource for", "resourceName", passedResourceName, "and resource type", resourceType)
+			return ctrl.Result{}, err
 		}
+		targets = []*scaleTarget{target}
 	}
 
-	log.V(1).Info("Checking for resource:", "type", resourceType, "name", passedResourceName)
+	// SPAs created from a ClusterScheduledPodAutoscaler leave resources scaled by namespaced SPAs overriding cluster policies alone:
+	if clusterPolicy, ok := scheduledPodAutoscaler.Labels[clusterPolicyLabel]; ok && resourceType != "hibernate" {
+		var overridden []string
+		var err error
+		targets, overridden, err = r.withoutOverriddenTargets(ctx, scheduledPodAutoscaler.Namespace, targets)
 
-	deploymentSpec, hpaSpec, err := getResourceSpec(passedResourceName, resourceType)
+		if err != nil {
+			log.Error(err, "unable to list SPAs overriding cluster policies")
+			return ctrl.Result{}, err
+		}
+		if len(overridden) > 0 {
+			log.V(1).Info("Leaving resources scaled by namespaced SPAs alone", "clusterPolicy", clusterPolicy, "resources", overridden)
+		}
+	}
 
-	if err != nil {
-		log.Error(err, "unable to find resource for", "resourceName", passedResourceName, "and resource type", resourceType)
-		return ctrl.Result{}, err
+	// fill in times, calendar and missing values from the referenced schedule template (the SPA itself is left as is in the cluster):
+	if scheduledPodAutoscaler.Spec.TemplateRef != nil {
+		if err := r.applyScheduleTemplate(ctx, scheduledPodAutoscaler); err != nil {
+			log.Error(err, "unable to fetch schedule template", "kind", scheduledPodAutoscaler.Spec.TemplateRef.Kind, "name", scheduledPodAutoscaler.Spec.TemplateRef.Name)
+			return ctrl.Result{}, err
+		}
 	}
 
 	// 4. (optional) - Check if we’re suspended (and don’t do anything else if we are)
@@ -144,9 +196,10 @@ func (r *ScheduledPodAutoscalerReconciler) Reconcile(ctx context.Context, req ct
 	// retrieve scaleUp spec:
 	var scaleUpTimeStr string
 	var scaleDownTimeStr string
-	var scaleUpValue *int32
-	var scaleDownValue *int32
-	var requiredReplicas *int32
+	var scaleUpValue *intstr.IntOrString
+	var scaleDownValue *intstr.IntOrString
+	var scheduledValue *intstr.IntOrString
+	var activeStep *autoscalingv1.ScaleSpec
 
 	scaleUpTimeStr = scheduledPodAutoscaler.Spec.ScaleUp.Time
 	scaleUpValue = scheduledPodAutoscaler.Spec.ScaleUp.Value
@@ -161,162 +214,686 @@ func (r *ScheduledPodAutoscalerReconciler) Reconcile(ctx context.Context, req ct
 		return ctrl.Result{}, err
 	}
 
-	// 6. Confirm which happens earlier - scaleup or scaledown:
-	curr_time := time.Now()
-	cur_year, cur_month, cur_day := (time.Now()).Date()
+	if resourceType != "hibernate" && (!scaleStepSet(scheduledPodAutoscaler.Spec.ScaleUp) || !scaleStepSet(scheduledPodAutoscaler.Spec.ScaleDown)) {
+		err := fmt.Errorf("scaleUp and scaleDown must set a value, profile or link - on the SPA or its schedule template")
+		return ctrl.Result{}, err
+	}
 
-	scaleUpTimeZero, _ := time.Parse(time.Kitchen, scaleUpTimeStr)
-	scaleDownTimeZero, _ := time.Parse(time.Kitchen, scaleDownTimeStr)
+	// 6. Work out the active step (in the time zone of the schedule) - as for ScheduledScalingGroups:
+	location, err := scheduleLocation(scheduledPodAutoscaler.Spec.ScheduleCalendar)
+	if err != nil {
+		log.Error(err, "unable to load time zone", "timeZone", scheduledPodAutoscaler.Spec.TimeZone)
+		return ctrl.Result{}, err
+	}
 
-	scaleUpHour, scaleUpMin, scaleUpSeconds := scaleUpTimeZero.Clock()
-	scaleDownHour, scaleDownMin, scaleDownSeconds := scaleDownTimeZero.Clock()
+	curr_time := time.Now().In(location)
 
-	ScaleUpTime := time.Date(cur_year, cur_month, cur_day, scaleUpHour, scaleUpMin, scaleUpSeconds, 0, time.Local)
-	ScaleDownTime := time.Date(cur_year, cur_month, cur_day, scaleDownHour, scaleDownMin, scaleDownSeconds, 0, time.Local)
+	scaleUpTimeZero, err := time.Parse(time.Kitchen, scaleUpTimeStr)
+	if err != nil {
+		log.Error(err, "invalid scaleUp.time", "time", scaleUpTimeStr)
+		return ctrl.Result{}, err
+	}
+	scaleDownTimeZero, err := time.Parse(time.Kitchen, scaleDownTimeStr)
+	if err != nil {
+		log.Error(err, "invalid scaleDown.time", "time", scaleDownTimeStr)
+		return ctrl.Result{}, err
+	}
+
+	// start scaling up ahead of scaleUp.time by the lead time - so capacity is in place at the declared time:
+	lead, err := leadTime(scheduledPodAutoscaler)
+	if err != nil {
+		log.Error(err, "unable to work out lead time", "leadTime", scheduledPodAutoscaler.Spec.LeadTime)
+		return ctrl.Result{}, err
+	}
+
+	// the lead time never reaches back to scaleDown:
+	lead = cappedLeadTime(lead, scaleUpTimeZero, scaleDownTimeZero)
+
+	scheduledPodAutoscaler.Status.EffectiveLeadTime = nil
+	if lead > 0 {
+		scheduledPodAutoscaler.Status.EffectiveLeadTime = &metav1.Duration{Duration: lead}
+	}
+
+	// the scaleUp step only starts on the days of the calendar that are no holidays - otherwise the scaleDown step carries on:
+	step, stepStart, err := activeScheduleStep(scheduledPodAutoscaler.Spec.ScheduleCalendar, scaleUpTimeStr, scaleDownTimeStr, lead, curr_time)
+	if err != nil {
+		log.Error(err, "unable to work out active step")
+		return ctrl.Result{}, err
+	}
 
 	// 7. Trigger scale action if required:
-	// check which action to take - later or earlier? (or later from a previous day?):
-	whichAction := func(earlierTime time.Time, laterTime time.Time, curTime time.Time) (actionType string) {
+	// scaleup funciton - scale only if current setup doesnt match required scale value:
+	// only the replica fields are patched so concurrent changes to the rest of the resource are kept.
+	// for autoscaler targets maxValue is the maxReplicas to apply along with minReplicas - nil leaves maxReplicas as is.
+	scaleResource := func(scaleValue *int32, maxValue *int32, target *scaleTarget) (appliedValue int32, required bool, err error) {
+		currentValue := target.replicas()
+
+		switch target.resourceType {
+		case "deployment", "statefulset", "scale":
+			if *scaleValue == *currentValue {
+				return *scaleValue, false, nil
+			} else {
+				nextValue := *scaleValue
+
+				if target.resourceType == "statefulset" {
+					var waiting bool
+					if nextValue, waiting = statefulSetScaleDownStep(target, *scaleValue); waiting {
+						log.V(1).Info("Waiting for the highest StatefulSet ordinal to terminate before scaling down further", "replicas", *currentValue)
+						return *currentValue, false, nil
+					}
+				}
 
-		if curTime.After(laterTime) {
-			actionType = "later"
-		} else if curTime.After(earlierTime) {
-			actionType = "earlier"
-		} else {
-			actionType = "laterYesterday"
+				if updateErr := r.updateScale(ctx, target, nextValue); updateErr != nil {
+					return *currentValue, false, updateErr
+				}
+				return nextValue, true, nil
+			}
+		case "hpa", "hpaOperator", "scaledObject":
+			currentMaxValue := target.maxReplicas()
+
+			if currentValue != nil && *scaleValue == *currentValue && (maxValue == nil || (currentMaxValue != nil && *maxValue == *currentMaxValue)) {
+				return *scaleValue, false, nil
+			} else {
+				patchErr := r.patchResource(ctx, target.gvk, target.key, func(u *unstructured.Unstructured) error {
+					if err := setReplicaField(u, target.scaledField, *scaleValue); err != nil {
+						return err
+					}
+
+					if maxValue != nil {
+						return setReplicaField(u, target.maxField, *maxValue)
+					}
+					return nil
+				})
+				if patchErr != nil {
+					return 0, false, patchErr
+				}
+				return *scaleValue, true, nil
+			}
 		}
+		scaleErr := fmt.Errorf("Failed to update resource %s - its neither a 'deployment', 'statefulset', 'hpa', 'annotatedDeployment', 'ScaledObject' nor a resource with a /scale subresource", target.resourceType)
+		return 0, false, scaleErr
+	}
 
-		return actionType
+	if step == "scaleUp" {
+		log.V(1).Info("Based on current time - current replicas must match ScaleUp.Value", "pods", scaleUpValue, "since", stepStart)
+		scheduledValue = scaleUpValue
+		activeStep = &scheduledPodAutoscaler.Spec.ScaleUp
+	} else {
+		log.V(1).Info("Based on current time - current replicas must match ScaleDown.Value", "pods", scaleDownValue, "since", stepStart)
+		scheduledValue = scaleDownValue
+		activeStep = &scheduledPodAutoscaler.Spec.ScaleDown
 	}
 
-	// scaleup funciton - scale only if current setup doesnt match required scale value:
-	scaleResource := func(scaleValue *int32, resourceType string, deploymentSpec *appsv1.Deployment, hpaSpec *kautoscalingv1.HorizontalPodAutoscaler) (required bool, err error) {
-		switch resourceType {
-		case "deployment":
-			if *scaleValue == *deploymentSpec.Spec.Replicas {
-				return false, nil
+	// placeholders run from placeholders.ahead before the next scale-up (on a day with a scaleUp) until it starts - sized
+	// for the value of the scaleUp step (or its profile); linked scaleUp steps get none, their value is only known at scale time:
+	var placeholderValue *intstr.IntOrString
+	cur_year, cur_month, cur_day := curr_time.Date()
+	scaleUpHour, scaleUpMin, scaleUpSeconds := scaleUpTimeZero.Clock()
+
+	nextScaleUp := time.Date(cur_year, cur_month, cur_day, scaleUpHour, scaleUpMin, scaleUpSeconds, 0, location).Add(-lead)
+	for !nextScaleUp.After(curr_time) {
+		nextScaleUp = nextScaleUp.AddDate(0, 0, 1)
+	}
+
+	if policy := scheduledPodAutoscaler.Spec.Placeholders; policy != nil && resourceType != "hibernate" && activeStep == &scheduledPodAutoscaler.Spec.ScaleDown &&
+		!curr_time.Before(nextScaleUp.Add(-placeholdersAhead(policy))) && scaleUpDay(scheduledPodAutoscaler.Spec.ScheduleCalendar, nextScaleUp.Add(lead)) {
+		placeholderValue = scheduledPodAutoscaler.Spec.ScaleUp.Value
+		if profile := findProfile(scheduledPodAutoscaler, scheduledPodAutoscaler.Spec.ScaleUp.Profile); profile != nil {
+			value := intstr.FromInt(int(profile.Value))
+			placeholderValue = &value
+		}
+	}
+
+	// the value (and maxReplicas) may come from a profile instead - selected by the step, or pinned:
+	stepMaxReplicas := activeStep.MaxReplicas
+	var pinHoldOff time.Duration
+
+	if resourceType != "hibernate" {
+		stepName := "scaleDown"
+		if activeStep == &scheduledPodAutoscaler.Spec.ScaleUp {
+			stepName = "scaleUp"
+		}
+
+		profile, reason, holdOff, err := resolveProfile(scheduledPodAutoscaler, activeStep, stepName, curr_time)
+		if err != nil {
+			log.Error(err, "unable to resolve capacity profile")
+			return ctrl.Result{}, err
+		}
+		pinHoldOff = holdOff
+
+		var profileName string
+		if profile != nil {
+			profileName = profile.Name
+			value := intstr.FromInt(int(profile.Value))
+			scheduledValue = &value
+			if profile.MaxReplicas != nil {
+				stepMaxReplicas = profile.MaxReplicas
+			}
+		}
+
+		if profileName != scheduledPodAutoscaler.Status.ActiveProfile || reason != scheduledPodAutoscaler.Status.ActiveProfileReason {
+			log.V(1).Info("Capacity profile changed", "profile", profileName, "reason", reason, "pods", scheduledValue)
+			if profileName != "" {
+				r.Recorder.Eventf(eventObject, corev1.EventTypeNormal, "Profile", "applying profile %s with %d replicas - %s", profileName, profile.Value, reason)
+			}
+		}
+		scheduledPodAutoscaler.Status.ActiveProfile = profileName
+		scheduledPodAutoscaler.Status.ActiveProfileReason = reason
+
+		// a linked step follows the current replicas of another workload:
+		if profile == nil && activeStep.Link != nil {
+			replicas, err := r.linkedReplicas(ctx, scheduledPodAutoscaler.Namespace, activeStep.Link)
+			if err != nil {
+				log.Error(err, "unable to fetch linked workload", "kind", linkKind(activeStep.Link), "name", activeStep.Link.Name)
+				return ctrl.Result{}, err
+			}
+
+			log.V(1).Info("Following linked workload", "kind", linkKind(activeStep.Link), "name", activeStep.Link.Name, "pods", replicas)
+			value := intstr.FromInt(int(replicas))
+			scheduledValue = &value
+		}
+	}
+
+	// 8. to 11. are taken for every target - status holds what the controller knows about the target:
+	reconcileTarget := func(target *scaleTarget, status *autoscalingv1.TargetStatus) (holdOff time.Duration, err error) {
+		log := log.WithValues("target", target.String())
+
+		// work out the replicas of the scheduled value - percentages are relative to the baseline of the target:
+		recordBaseline(target, status, scheduledPodAutoscaler.Spec.Baseline, stepStart, curr_time)
+
+		var requiredReplicas *int32
+		if scheduledValue.Type == intstr.Int {
+			requiredReplicas = &scheduledValue.IntVal
+		} else {
+			baseline, err := baselineReplicas(status, scheduledPodAutoscaler.Spec.Baseline, stepStart)
+			if err != nil {
+				log.Error(err, "unable to work out baseline of percentage value", "value", scheduledValue.String())
+				return 0, err
+			}
+
+			replicas, err := scaledReplicas(*scheduledValue, baseline, scheduledPodAutoscaler.Spec.Baseline)
+			if err != nil {
+				return 0, err
+			}
+			log.V(1).Info("Percentage value relative to baseline", "value", scheduledValue.String(), "baseline", baseline, "pods", replicas)
+			requiredReplicas = &replicas
+		}
+
+		// HPAs can't have minReplicas 0 (without the HPAScaleToZero feature gate) - the resource they scale is scaled to
+		// zero instead (see 10.), and minReplicas is left as is (or at the HPA's default of 1):
+		zeroScaleTarget := *requiredReplicas == 0 && (resourceType == "hpa" || resourceType == "hpaOperator")
+		if zeroScaleTarget {
+			requiredReplicas = target.replicas()
+			if requiredReplicas == nil {
+				one := int32(1)
+				requiredReplicas = &one
+			}
+		}
+
+		// Autoscaler targets only - work out maxReplicas for the active step (restoring the original one once the scale-down window begins):
+		var requiredMaxReplicas *int32
+		restoresMaxReplicas := false
+
+		if target.maxField != nil {
+			requiredMaxReplicas, restoresMaxReplicas = autoscalerMaxReplicas(target.maxReplicas(), stepMaxReplicas, status.OriginalMaxReplicas,
+				activeStep == &scheduledPodAutoscaler.Spec.ScaleDown, *requiredReplicas)
+			if restoresMaxReplicas {
+				log.V(1).Info("Scale-down window began - restoring original maxReplicas", "maxReplicas", *requiredMaxReplicas)
+			}
+		}
+
+		// never scale a StatefulSet below the ordinals whose volumes are marked as critical (if asked to):
+		if resourceType == "statefulset" && scheduledPodAutoscaler.Spec.Resource.ProtectCriticalVolumes {
+			floor, err := r.criticalVolumeReplicas(ctx, target)
+			if err != nil {
+				log.Error(err, "unable to list volumes of StatefulSet", "named", target.key.Name)
+				return 0, err
+			}
+
+			if *requiredReplicas < floor {
+				log.V(1).Info("Scheduled replicas would remove pods with critical volumes - scaling to lowest safe count instead", "pods", requiredReplicas, "safePods", floor)
+				r.Recorder.Eventf(eventObject, corev1.EventTypeWarning, "CriticalVolumes",
+					"refusing to scale statefulset %s below %d replicas - PVCs of lower ordinals are annotated %s", target.key.Name, floor, criticalVolumeAnnotation)
+				requiredReplicas = &floor
+			}
+		}
+
+		// make sure the pods of a scale-up fit the namespace's quotas (and the nodes) - or only scale up as far as they do:
+		if scheduledPodAutoscaler.Spec.Preflight != nil {
+			replicas, err := r.preflightScaleUp(ctx, eventObject, target, status, scheduledPodAutoscaler.Spec.Preflight, *requiredReplicas)
+			if err != nil {
+				log.Error(err, "unable to check whether scale-up fits", "named", target.key.Name)
+				return 0, err
+			}
+
+			if replicas != *requiredReplicas {
+				log.V(1).Info("Scale-up doesn't fit - scaling up as far as it does", "pods", requiredReplicas, "fittingPods", replicas)
+				requiredReplicas = &replicas
+			}
+		}
+
+		// 8. Respect a recent manual change of the scaled field for the configured grace period:
+		currentReplicas := target.replicas()
+		managedFields := target.managedFields()
+		scaledField := target.fieldName()
+
+		skipScaling := false
+
+		// the field was changed by hand if it no longer holds what the controller applied last, nor what the schedule asks for:
+		gracePeriod := scheduledPodAutoscaler.Spec.ManualOverrideGracePeriod
+		manuallyScaled := changedByHand(gracePeriod, currentReplicas, status.LastAppliedReplicas, *requiredReplicas)
+
+		if manuallyScaled {
+			if status.ManualOverrideTime == nil {
+				r.Recorder.Eventf(eventObject, corev1.EventTypeNormal, "ManualOverride",
+					"%s of %s was changed to %d outside of the schedule (last applied %d) - leaving it alone for %s",
+					scaledField, target, *currentReplicas, *status.LastAppliedReplicas, gracePeriod.Duration)
+				status.ManualOverrideTime = &metav1.Time{Time: curr_time}
+			}
+
+			if remaining := manualOverrideRemaining(status.ManualOverrideTime.Time, gracePeriod.Duration, curr_time); remaining > 0 {
+				log.V(1).Info("Scaled field was changed by hand - leaving it alone until grace period expires", "podsCount", *currentReplicas, "remaining", remaining)
+				skipScaling = true
+				holdOff = remaining
 			} else {
-				u := &unstructured.Unstructured{}
+				r.Recorder.Eventf(eventObject, corev1.EventTypeNormal, "ResumingSchedule",
+					"manual override grace period of %s expired - resuming schedule for %s with %d replicas",
+					gracePeriod.Duration, target, *requiredReplicas)
+				status.ManualOverrideTime = nil
+			}
+		} else {
+			status.ManualOverrideTime = nil
+		}
+
+		// 9. Check if another writer keeps overwriting the scaled field (and back off if configured to) - the /scale subresource
+		// carries no managedFields, so resources scaled through it are never found contested (manual changes are still noticed in 8.):
+		var contestedBy string
+		if target.scaledField != nil {
+			contestedBy = competingFieldManager(managedFields, target.scaledField...)
+		} else if scheduledPodAutoscaler.Spec.OnContention != nil {
+			log.V(1).Info("Competing writers can't be detected for resources scaled through /scale - ignoring onContention", "resource", target.String())
+		}
+
+		// the field is only contested if it drifted away from the schedule after the controller already applied it
+		// (and isn't being left alone as a manual override):
+		if manuallyScaled || status.LastScheduleTime == nil || currentReplicas == nil || *currentReplicas == *requiredReplicas {
+			contestedBy = ""
+		}
 
-				deploymentSpec.Spec.Replicas = scaleValue
+		contentionAction, contentionBase := contentionPolicy(scheduledPodAutoscaler)
 
-				var convErr error
+		if contestedBy != "" {
+			log.V(1).Info("Scaled field was overwritten by another field manager", "field", scaledField, "manager", contestedBy, "action", contentionAction)
 
-				u.Object, convErr = runtime.DefaultUnstructuredConverter.ToUnstructured(&deploymentSpec)
-				if convErr != nil {
-					return false, convErr
+			message := fmt.Sprintf("%s of %s is being written by field manager %q", scaledField, target, contestedBy)
+			if contested := meta.FindStatusCondition(status.Conditions, autoscalingv1.ConditionContested); contested == nil || contested.Status != metav1.ConditionTrue || contested.Message != message {
+				r.Recorder.Event(eventObject, corev1.EventTypeWarning, autoscalingv1.ConditionContested, message)
+			}
+
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:    autoscalingv1.ConditionContested,
+				Status:  metav1.ConditionTrue,
+				Reason:  "CompetingWriter",
+				Message: message,
+			})
+
+			switch contentionAction {
+			case autoscalingv1.ContentionActionYield:
+				skipScaling = true
+			case autoscalingv1.ContentionActionBackoff:
+				if status.LastContestedTime != nil {
+					holdOff = status.LastContestedTime.Add(contentionBackoff(contentionBase, status.ContestedCount)).Sub(curr_time)
+					skipScaling = holdOff > 0
 				}
+			}
+
+			if !skipScaling || status.LastContestedTime == nil {
+				status.ContestedCount++
+				status.LastContestedTime = &metav1.Time{Time: curr_time}
+			}
+		} else if target.scaledField != nil && (status.LastContestedTime == nil || curr_time.After(status.LastContestedTime.Add(contentionBackoff(contentionBase, status.ContestedCount)))) {
+			// nobody overwrote the field for a full backoff period - consider the resource settled:
+			status.ContestedCount = 0
+			status.LastContestedTime = nil
+
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:    autoscalingv1.ConditionContested,
+				Status:  metav1.ConditionFalse,
+				Reason:  "NoCompetingWriter",
+				Message: fmt.Sprintf("%s of %s is only written by the controller", scaledField, target),
+			})
+		}
 
-				updateErr := r.Update(ctx, u)
-				if updateErr != nil {
-					return false, updateErr
+		// 10. Check if scaling is required - trigger the scaleResource func:
+		if skipScaling {
+			log.V(1).Info("Leaving scaled field as is for now", "podsCount", currentReplicas)
+		} else {
+			// steer which pods a scale-down removes - by their pod-deletion-cost, set before the replicas are lowered:
+			if scheduledPodAutoscaler.Spec.ScaleDownStrategy != nil && currentReplicas != nil && *requiredReplicas < *currentReplicas {
+				steered, err := r.steerScaleDown(ctx, scheduledPodAutoscaler, target, status, curr_time)
+				if err != nil {
+					log.Error(err, "unable to set pod-deletion-cost ahead of scale-down", "strategy", scheduledPodAutoscaler.Spec.ScaleDownStrategy.Type)
+				} else if steered > 0 {
+					log.V(1).Info("Set pod-deletion-cost ahead of scale-down", "strategy", scheduledPodAutoscaler.Spec.ScaleDownStrategy.Type, "pods", steered)
 				}
-				return true, nil
 			}
-		case "hpa":
-			if *scaleValue == *hpaSpec.Spec.MinReplicas {
-				return false, nil
+
+			appliedReplicas, requiredScaling, err := scaleResource(requiredReplicas, requiredMaxReplicas, target)
+
+			// log outcome:
+			if err != nil {
+				log.Error(err, "unable to scale resource", "type", resourceType, "named", target.key.Name)
+			} else if requiredScaling {
+				log.V(1).Info("Scaling process was required and contoller successfully scaled to", "podsCount", appliedReplicas)
+				status.LastScheduleTime = &metav1.Time{Time: curr_time}
+				startReadinessTracking(status, currentReplicas, appliedReplicas, curr_time)
 			} else {
-				u := &unstructured.Unstructured{}
+				log.V(1).Info("Replica count already matched required setup with", "podsCount alreadt at", requiredReplicas)
+			}
 
-				hpaSpec.Spec.MinReplicas = scaleValue
+			if err == nil {
+				status.LastAppliedReplicas = &appliedReplicas
 
-				if *scaleValue > hpaSpec.Spec.MaxReplicas {
-					log.V(1).Info("maxReplicas is lower than required scaling and new minReplicas", "maxReplicas", hpaSpec.Spec.MaxReplicas, "new minReplicas", scaleValue)
-					log.V(1).Info("setting maxReplicas to new minReplicas", "maxReplicas", scaleValue)
-					hpaSpec.Spec.MaxReplicas = *scaleValue
+				// remember the maxReplicas the HPA had before the controller first changed it - and forget it once restored:
+				if requiredMaxReplicas != nil {
+					currentMaxReplicas := target.maxReplicas()
+
+					if restoresMaxReplicas {
+						status.OriginalMaxReplicas = nil
+					} else if status.OriginalMaxReplicas == nil && currentMaxReplicas != nil && *requiredMaxReplicas != *currentMaxReplicas {
+						status.OriginalMaxReplicas = currentMaxReplicas
+					}
+				}
+			}
+
+			// HPAs only - scale the resource scaled by the HPA to zero during a step with value 0, and restore it after:
+			if zeroScaleTarget || status.OriginalTargetReplicas != nil {
+				scaled, changed, zeroErr := r.zeroHPAScaleTarget(ctx, target, zeroScaleTarget, *requiredReplicas, status)
+
+				if zeroErr != nil {
+					log.Error(zeroErr, "unable to scale the resource scaled by the HPA", "toZero", zeroScaleTarget)
+				} else if changed && zeroScaleTarget {
+					log.V(1).Info("Scaled the resource scaled by the HPA to zero", "resource", scaled.String())
+					r.Recorder.Eventf(eventObject, corev1.EventTypeNormal, "ScaledToZero", "scaled %s to zero - %s is paused until the next step", scaled, target)
+					status.LastScheduleTime = &metav1.Time{Time: curr_time}
+				} else if changed {
+					log.V(1).Info("Restored the resource scaled by the HPA", "resource", scaled.String())
+					r.Recorder.Eventf(eventObject, corev1.EventTypeNormal, "RestoredFromZero", "scaled %s back up - %s is active again", scaled, target)
+					status.LastScheduleTime = &metav1.Time{Time: curr_time}
+					startReadinessTracking(status, nil, *requiredReplicas, curr_time)
 				}
+			}
+		}
 
-				var convErr error
+		// 11. HPAs only - apply the hpaProfile of the active step when the step starts (or restore the original
+		// metrics and behavior if the step has none):
+		if resourceType == "hpa" {
+			var activeHPAProfile string
+			if activeStep.HPAProfile != nil && activeStep == &scheduledPodAutoscaler.Spec.ScaleUp {
+				activeHPAProfile = "scaleUp"
+			} else if activeStep.HPAProfile != nil {
+				activeHPAProfile = "scaleDown"
+			}
 
-				u.Object, convErr = runtime.DefaultUnstructuredConverter.ToUnstructured(&hpaSpec)
-				if convErr != nil {
-					return false, convErr
+			if activeHPAProfile != status.ActiveHPAProfile {
+				// the fields only the previously applied profile set are restored:
+				var previousHPAProfile *autoscalingv1.HPAProfile
+				switch status.ActiveHPAProfile {
+				case "scaleUp":
+					previousHPAProfile = scheduledPodAutoscaler.Spec.ScaleUp.HPAProfile
+				case "scaleDown":
+					previousHPAProfile = scheduledPodAutoscaler.Spec.ScaleDown.HPAProfile
 				}
 
-				updateErr := r.Update(ctx, u)
-				if updateErr != nil {
-					return false, updateErr
+				patched, profileErr := r.applyHPAProfile(ctx, target, activeStep.HPAProfile, previousHPAProfile, status)
+
+				if profileErr != nil {
+					log.Error(profileErr, "unable to apply hpaProfile", "step", activeHPAProfile, "named", target.key.Name)
+				} else if activeHPAProfile != "" {
+					log.V(1).Info("Applied hpaProfile of the active step", "step", activeHPAProfile)
+					r.Recorder.Eventf(eventObject, corev1.EventTypeNormal, "HPAProfile", "applied hpaProfile of %s to %s", activeHPAProfile, target)
+					status.ActiveHPAProfile = activeHPAProfile
+				} else {
+					if patched {
+						log.V(1).Info("Restored original metrics and behavior")
+						r.Recorder.Eventf(eventObject, corev1.EventTypeNormal, "HPAProfile", "restored original metrics and behavior of %s", target)
+					}
+					status.ActiveHPAProfile = ""
 				}
-				return true, nil
 			}
 		}
-		scaleErr := fmt.Errorf("Failed to update resource %s - its neither a 'deployment' nor 'hpa'", resourceType)
-		return false, scaleErr
-	}
-
-	switch ScaleUpTime.Before(ScaleDownTime) {
-	// when scaleup is before scaledown
-	case true:
-		actionType := whichAction(ScaleUpTime, ScaleDownTime, curr_time)
-		switch actionType {
-		case "earlier":
-			log.V(1).Info("Based on current time - current replicas must match ScaleUp.Value", "pods", scaleUpValue)
-			log.V(1).Info("Checking if scaleUp is required and taking actions if necessairy")
-			requiredReplicas = scaleUpValue
-		case "later":
-			log.V(1).Info("Based on current time - current replicas must match ScaleDown.Value", "pods", scaleDownValue)
-			log.V(1).Info("Checking if scaleDown is required and taking actions if necessairy")
-			requiredReplicas = scaleDownValue
-		case "laterYesterday":
-			log.V(1).Info("Based on current time - no actions are required for today. Current replicas must match ScaleDown.Value", "pods from yesterday", scaleDownValue)
-			log.V(1).Info("Checking if scaleDown is required and taking actions if necessairy")
-			requiredReplicas = scaleDownValue
-		}
-	// when scaleup is after scaledown
-	case false:
-		actionType := whichAction(ScaleDownTime, ScaleUpTime, curr_time)
-		switch actionType {
-		case "earlier":
-			log.V(1).Info("Based on current time - current replicas must match ScaleDown.Value", "pods", scaleDownValue)
-			log.V(1).Info("Checking if scaleDown is required and taking actions if necessairy")
-			requiredReplicas = scaleDownValue
-		case "later":
-			log.V(1).Info("Based on current time - current replicas must match ScaleUp.Value", "pods", scaleUpValue)
-			log.V(1).Info("Checking if scaleUp is required and taking actions if necessairy")
-			requiredReplicas = scaleUpValue
-		case "laterYesterday":
-			log.V(1).Info("Based on current time - no actions are required for today. Current replicas must match scaleUpValue.Value", "pods from yesterday", scaleUpValue)
-			log.V(1).Info("Checking if scaleUp is required and taking actions if necessairy")
-			requiredReplicas = scaleUpValue
-		}
-	}
-
-	// check if scaleup is required - trigger the scaleResource func:
-	requiredScaling, err := scaleResource(requiredReplicas, resourceType, deploymentSpec, hpaSpec)
-
-	// log outcome:
-	if err != nil {
-		log.Error(err, "unable to scale resource", "type", resourceType, "named", passedResourceName)
-	} else if requiredScaling {
-		log.V(1).Info("Scaling process was required and contoller successfully scaled to", "podsCount", requiredReplicas)
-	} else {
-		log.V(1).Info("Replica count already matched required setup with", "podsCount alreadt at", requiredReplicas)
+
+		// 11b. Follow the last scale-up until its pods are ready - reporting the SPA as Degraded past the readinessDeadline:
+		readinessHoldOff, err := r.trackReadiness(ctx, scheduledPodAutoscaler, eventObject, target, status, scheduledPodAutoscaler.Spec.ReadinessDeadline, curr_time)
+		if err != nil {
+			log.Error(err, "unable to check readiness of scale-up")
+		} else if readinessHoldOff > 0 && (holdOff <= 0 || readinessHoldOff < holdOff) {
+			holdOff = readinessHoldOff
+		}
+
+		// 11c. Run placeholder pods ahead of the next scale-up - removed once it starts (right after scaling), so they no
+		// longer hold the room and quota its pods need:
+		if scheduledPodAutoscaler.Spec.Placeholders != nil && scheduledPodAutoscaler.UID != "" {
+			var placeholderReplicas int32
+			if placeholderValue != nil {
+				scaleUpReplicas := placeholderValue.IntVal
+				if placeholderValue.Type == intstr.String {
+					baseline, err := baselineReplicas(status, scheduledPodAutoscaler.Spec.Baseline, nextScaleUp)
+					if err == nil {
+						scaleUpReplicas, err = scaledReplicas(*placeholderValue, baseline, scheduledPodAutoscaler.Spec.Baseline)
+					}
+					if err != nil {
+						log.Error(err, "unable to work out replicas of the next scale-up", "value", placeholderValue.String())
+					}
+				}
+
+				if placeholderReplicas, err = r.placeholderReplicas(ctx, target, scaleUpReplicas); err != nil {
+					log.Error(err, "unable to work out placeholder pods")
+				}
+			}
+
+			if err := r.reconcilePlaceholders(ctx, scheduledPodAutoscaler, eventObject, target, status, placeholderReplicas); err != nil {
+				log.Error(err, "unable to reconcile placeholder pods", "placeholders", placeholderReplicas)
+			}
+		}
+
+		// 11d. Remove the pod-deletion-costs set for the last scale-down once it is through:
+		if err := r.clearDeletionCosts(ctx, scheduledPodAutoscaler, target, status, curr_time); err != nil {
+			log.Error(err, "unable to remove pod-deletion-cost of pods")
+		}
+		return holdOff, nil
 	}
 
-	// 8. Requeue reconciliation and return to manager:
+	var holdOff time.Duration
+	var targetErr error
+	spaKey := types.NamespacedName{Namespace: scheduledPodAutoscaler.Namespace, Name: scheduledPodAutoscaler.Name}
 
-	// retrieve the rate of requeuing reconciliation loop:
-	requeueRate := os.Getenv("RequeueRate")
-	var requeueRateNS time.Duration
-	var prsDurErr error
+	if resourceType == "hibernate" {
+		// the namespace sleeps during the scaleDown window:
+		asleep := activeStep == &scheduledPodAutoscaler.Spec.ScaleDown
 
-	if requeueRate == "" {
-		requeueRateNS, prsDurErr = time.ParseDuration("10s")
+		changed, err := r.hibernateNamespace(ctx, scheduledPodAutoscaler.Namespace, scheduledPodAutoscaler.Name, asleep)
+		if err != nil {
+			log.Error(err, "unable to hibernate namespace", "asleep", asleep)
+			targetErr = err
+		}
+
+		if len(changed) > 0 && asleep {
+			log.V(1).Info("Scaled workloads to zero", "workloads", changed)
+			r.Recorder.Eventf(eventObject, corev1.EventTypeNormal, "Hibernated", "scaled %s to zero", strings.Join(changed, ", "))
+			scheduledPodAutoscaler.Status.LastScheduleTime = &metav1.Time{Time: curr_time}
+		} else if len(changed) > 0 {
+			log.V(1).Info("Restored original replicas of workloads", "workloads", changed)
+			r.Recorder.Eventf(eventObject, corev1.EventTypeNormal, "WokeUp", "restored original replicas of %s", strings.Join(changed, ", "))
+			scheduledPodAutoscaler.Status.LastScheduleTime = &metav1.Time{Time: curr_time}
+		}
+
+		hibernating := metav1.Condition{
+			Type:    autoscalingv1.ConditionHibernating,
+			Status:  metav1.ConditionFalse,
+			Reason:  "Awake",
+			Message: "workloads of the namespace run with their original replicas",
+		}
+		if asleep {
+			hibernating.Status, hibernating.Reason, hibernating.Message = metav1.ConditionTrue, "Asleep", "workloads of the namespace are scaled to zero"
+		}
+		meta.SetStatusCondition(&scheduledPodAutoscaler.Status.Conditions, hibernating)
+	} else if scheduledPodAutoscaler.Spec.Resource.Selector == nil {
+		scheduledPodAutoscaler.Status.Targets = nil
+
+		// the named resource may be overridden by a namespaced SPA:
+		if len(targets) > 0 {
+			holdOff, targetErr = reconcileTarget(targets[0], &scheduledPodAutoscaler.Status.TargetStatus)
+			retainTimeToReady(spaKey, targets[0].key.Name)
+		} else {
+			retainTimeToReady(spaKey)
+		}
 	} else {
-		requeueRateNS, prsDurErr = time.ParseDuration(requeueRate)
+		statuses, added, removed := syncTargetStatuses(&scheduledPodAutoscaler.Status, targets)
+
+		for _, name := range added {
+			r.Recorder.Eventf(eventObject, corev1.EventTypeNormal, "TargetAdded", "%s %s matches the selector - scaling it from now on", resourceType, name)
+		}
+		for _, name := range removed {
+			r.Recorder.Eventf(eventObject, corev1.EventTypeNormal, "TargetRemoved", "%s %s is gone or no longer matches the selector - no longer scaling it", resourceType, name)
+		}
+		if len(removed) > 0 {
+			names := make([]string, 0, len(targets))
+			for _, target := range targets {
+				names = append(names, target.key.Name)
+			}
+			retainTimeToReady(spaKey, names...)
+		}
+
+		// a failing target doesn't keep the others from being scaled:
+		for i, target := range targets {
+			targetHoldOff, err := reconcileTarget(target, statuses[i])
+			if err != nil && targetErr == nil {
+				targetErr = err
+			}
+			if targetHoldOff > 0 && (holdOff <= 0 || targetHoldOff < holdOff) {
+				holdOff = targetHoldOff
+			}
+		}
+
+		summarizeContention(&scheduledPodAutoscaler.Status)
 	}
 
-	if prsDurErr != nil {
-		log.Error(prsDurErr, "unable parse duration for reconcilation requeue rate", "value", requeueRate)
+	// placeholders of targets that are gone (or of an SPA without spec.placeholders) are removed:
+	if scheduledPodAutoscaler.UID != "" && hasPlaceholders(scheduledPodAutoscaler) {
+		if err := r.removeStalePlaceholders(ctx, scheduledPodAutoscaler, targets); err != nil {
+			log.Error(err, "unable to remove stale placeholder pods")
+		}
+	}
+
+	// come back when a pinned profile expires:
+	if pinHoldOff > 0 && (holdOff <= 0 || pinHoldOff < holdOff) {
+		holdOff = pinHoldOff
+	}
+
+	if err := updateStatus(); err != nil {
+		log.Error(err, "unable to update ScheduledPodAutoscaler status")
+		return ctrl.Result{}, err
+	}
+
+	if targetErr != nil {
+		return ctrl.Result{}, targetErr
+	}
+
+	// 12. Requeue reconciliation and return to manager:
+
+	// retrieve the rate of requeuing reconciliation loop:
+	requeueRateNS := requeueRate(log)
+
+	// come back earlier if a grace period or contention backoff runs out before the next regular requeue:
+	if holdOff > 0 && holdOff < requeueRateNS {
+		requeueRateNS = holdOff
 	}
 
 	// return to manager if no errors occured along the way:
 	return ctrl.Result{RequeueAfter: requeueRateNS}, nil
 }
 
+// requeueRate returns the rate reconciliations are requeued at - the RequeueRate environment variable, or 10s if
+// it is unset (or invalid).
+func requeueRate(log logr.Logger) time.Duration {
+	requeueRate := os.Getenv("RequeueRate")
+	if requeueRate == "" {
+		return 10 * time.Second
+	}
+
+	requeueRateNS, err := time.ParseDuration(requeueRate)
+	if err != nil {
+		log.Error(err, "unable parse duration for reconcilation requeue rate", "value", requeueRate)
+		return 10 * time.Second
+	}
+	return requeueRateNS
+}
+
+// patchResource re-reads the resource (from the API server - not the cache), lets mutate change it and sends the difference as a merge patch.
+// The patch carries the resourceVersion it was computed against, so a concurrent write
+// results in a conflict - in which case the whole read-mutate-patch cycle is retried.
+func (r *ScheduledPodAutoscalerReconciler) patchResource(ctx context.Context, gvk schema.GroupVersionKind, key client.ObjectKey, mutate func(u *unstructured.Unstructured) error) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(gvk)
+
+		if err := r.apiReader().Get(ctx, key, u); err != nil {
+			return err
+		}
+
+		patch := client.MergeFromWithOptions(u.DeepCopy(), client.MergeFromWithOptimisticLock{})
+
+		if err := mutate(u); err != nil {
+			return err
+		}
+
+		return r.Patch(ctx, u, patch, client.FieldOwner(fieldManager))
+	})
+}
+
+// apiReader returns the reader patches are built from - an uncached one if set.
+func (r *ScheduledPodAutoscalerReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
 func (r *ScheduledPodAutoscalerReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	// index SPAs by their templateRef, so the SPAs of a changed template are found without listing every SPA:
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &autoscalingv1.ScheduledPodAutoscaler{}, templateRefIndex, func(obj client.Object) []string {
+		if key := templateRefKey(obj.(*autoscalingv1.ScheduledPodAutoscaler)); key != "" {
+			return []string{key}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// index SPAs by the workloads their scale steps follow, so changes of a workload propagate right away:
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &autoscalingv1.ScheduledPodAutoscaler{}, linkIndex, func(obj client.Object) []string {
+		return linkKeys(obj.(*autoscalingv1.ScheduledPodAutoscaler))
+	})
+	if err != nil {
+		return err
+	}
+
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&autoscalingv1.ScheduledPodAutoscaler{}).
-		Complete(r)
+		Watches(&source.Kind{Type: &autoscalingv1.ScheduleTemplate{}}, handler.EnqueueRequestsFromMapFunc(r.referencingScheduledPodAutoscalers(autoscalingv1.ScheduleTemplateKind))).
+		Watches(&source.Kind{Type: &autoscalingv1.ClusterScheduleTemplate{}}, handler.EnqueueRequestsFromMapFunc(r.referencingScheduledPodAutoscalers(autoscalingv1.ClusterScheduleTemplateKind)))
+
+	// reconcile SPAs with a selector (or in Hibernate mode) as soon as resources they select appear, change their labels or disappear:
+	for _, kind := range r.selectableTargetKinds() {
+		builder = builder.Watches(&source.Kind{Type: kind}, handler.EnqueueRequestsFromMapFunc(r.selectingScheduledPodAutoscalers(kind.GroupVersionKind())))
+	}
+
+	// ... and SPAs with a linked scale step as soon as the replicas of the workload they follow change:
+	for _, kind := range watchedTargetKinds() {
+		builder = builder.Watches(&source.Kind{Type: kind}, handler.EnqueueRequestsFromMapFunc(r.linkingScheduledPodAutoscalers(kind.GroupVersionKind())))
+	}
+
+	return builder.Complete(r)
 }
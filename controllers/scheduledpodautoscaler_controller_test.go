@@ -0,0 +1,269 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/scale"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+// newTestReconciler wires a ScheduledPodAutoscalerReconciler against the test environment.
+func newTestReconciler() *ScheduledPodAutoscalerReconciler {
+	mapper, err := apiutil.NewDynamicRESTMapper(cfg)
+	Expect(err).NotTo(HaveOccurred())
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
+	Expect(err).NotTo(HaveOccurred())
+
+	scaleClient, err := scale.NewForConfig(cfg, mapper, dynamic.LegacyAPIPathResolverFunc, scale.NewDiscoveryScaleKindResolver(discoveryClient))
+	Expect(err).NotTo(HaveOccurred())
+
+	return &ScheduledPodAutoscalerReconciler{
+		Client:      k8sClient,
+		APIReader:   k8sClient,
+		Log:         logf.Log.WithName("controllers").WithName("ScheduledPodAutoscaler"),
+		Scheme:      scheme.Scheme,
+		Recorder:    record.NewFakeRecorder(100),
+		Mapper:      mapper,
+		ScaleClient: scaleClient,
+	}
+}
+
+// newTestSPA returns an SPA scaling the named resource to 4 an hour ago and to 3 in an hour (UTC) - so the scaleUp step is active.
+func newTestSPA(name string, resource autoscalingv1.Resource) *autoscalingv1.ScheduledPodAutoscaler {
+	scaleUpValue, scaleDownValue := intstr.FromInt(4), intstr.FromInt(3)
+	now := time.Now().UTC()
+
+	return &autoscalingv1.ScheduledPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: autoscalingv1.ScheduledPodAutoscalerSpec{
+			Resource:         resource,
+			ScaleUp:          autoscalingv1.ScaleSpec{Time: now.Add(-time.Hour).Format(time.Kitchen), Value: &scaleUpValue},
+			ScaleDown:        autoscalingv1.ScaleSpec{Time: now.Add(time.Hour).Format(time.Kitchen), Value: &scaleDownValue},
+			ScheduleCalendar: autoscalingv1.ScheduleCalendar{TimeZone: "UTC"},
+		},
+	}
+}
+
+// reconcileTestSPA creates the SPA in the default namespace and reconciles it once.
+func reconcileTestSPA(ctx context.Context, reconciler *ScheduledPodAutoscalerReconciler, spa *autoscalingv1.ScheduledPodAutoscaler) {
+	Expect(k8sClient.Create(ctx, spa)).To(Succeed())
+
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: spa.Name, Namespace: spa.Namespace}})
+	Expect(err).NotTo(HaveOccurred())
+}
+
+// getTestObject fetches the named object of the default namespace.
+func getTestObject(ctx context.Context, gvk schema.GroupVersionKind, name string) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gvk)
+	Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "default"}, object)).To(Succeed())
+	return object
+}
+
+// newTestDeployment returns a Deployment of the pause image with the given replicas.
+func newTestDeployment(name string, namespace string, replicas int64) *unstructured.Unstructured {
+	deployment := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+		},
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"app": name},
+			},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"labels": map[string]interface{}{"app": name},
+				},
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "app", "image": "k8s.gcr.io/pause:3.2"},
+					},
+				},
+			},
+		},
+	}}
+	deployment.SetGroupVersionKind(deploymentGVK)
+	return deployment
+}
+
+var _ = Describe("ScheduledPodAutoscaler controller", func() {
+	ctx := context.Background()
+
+	Context("when patching a resource", func() {
+		It("keeps changes other writers made to the rest of the resource - retrying on conflicts", func() {
+			reconciler := newTestReconciler()
+
+			deployment := newTestDeployment("patch-test", "default", 1)
+			Expect(k8sClient.Create(ctx, deployment)).To(Succeed())
+			key := types.NamespacedName{Name: "patch-test", Namespace: "default"}
+
+			// another writer changes the resource in between the read and the patch of the first attempt:
+			attempts := 0
+			err := reconciler.patchResource(ctx, deploymentGVK, key, func(u *unstructured.Unstructured) error {
+				attempts++
+				if attempts == 1 {
+					other := &unstructured.Unstructured{}
+					other.SetGroupVersionKind(deploymentGVK)
+					Expect(k8sClient.Get(ctx, key, other)).To(Succeed())
+					other.SetLabels(map[string]string{"team": "checkout"})
+					Expect(k8sClient.Update(ctx, other)).To(Succeed())
+				}
+				return setReplicaField(u, []string{"spec", "replicas"}, 5)
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attempts).To(Equal(2))
+
+			after := getTestObject(ctx, deploymentGVK, "patch-test")
+
+			replicas, _, _ := unstructured.NestedInt64(after.Object, "spec", "replicas")
+			Expect(replicas).To(Equal(int64(5)))
+			Expect(after.GetLabels()).To(HaveKeyWithValue("team", "checkout"))
+		})
+	})
+
+	Context("with an autoscaling/v2 HorizontalPodAutoscaler", func() {
+		It("only changes the replica bounds and keeps metrics, behavior and annotations", func() {
+			reconciler := newTestReconciler()
+
+			mapping, err := reconciler.Mapper.RESTMapping(hpaGroupKind, hpaVersions...)
+			Expect(err).NotTo(HaveOccurred())
+
+			hpa := &unstructured.Unstructured{Object: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name":      "hpa-v2-test",
+					"namespace": "default",
+					"annotations": map[string]interface{}{
+						"team": "checkout",
+					},
+				},
+				"spec": map[string]interface{}{
+					"scaleTargetRef": map[string]interface{}{
+						"apiVersion": "apps/v1",
+						"kind":       "Deployment",
+						"name":       "hpa-v2-test",
+					},
+					"minReplicas": int64(1),
+					"maxReplicas": int64(10),
+					"metrics": []interface{}{
+						map[string]interface{}{
+							"type": "Resource",
+							"resource": map[string]interface{}{
+								"name":   "memory",
+								"target": map[string]interface{}{"type": "Utilization", "averageUtilization": int64(70)},
+							},
+						},
+						map[string]interface{}{
+							"type": "Pods",
+							"pods": map[string]interface{}{
+								"metric": map[string]interface{}{"name": "queue_depth"},
+								"target": map[string]interface{}{"type": "AverageValue", "averageValue": "30"},
+							},
+						},
+					},
+					"behavior": map[string]interface{}{
+						"scaleDown": map[string]interface{}{
+							"stabilizationWindowSeconds": int64(600),
+							"policies": []interface{}{
+								map[string]interface{}{"type": "Percent", "value": int64(10), "periodSeconds": int64(60)},
+							},
+						},
+					},
+				},
+			}}
+			hpa.SetGroupVersionKind(mapping.GroupVersionKind)
+			Expect(k8sClient.Create(ctx, hpa)).To(Succeed())
+
+			// compare against the object as defaulted by the API server:
+			before := getTestObject(ctx, mapping.GroupVersionKind, "hpa-v2-test")
+
+			reconcileTestSPA(ctx, reconciler, newTestSPA("hpa-v2-test", autoscalingv1.Resource{Name: "hpa-v2-test", Type: "HPA"}))
+
+			after := getTestObject(ctx, mapping.GroupVersionKind, "hpa-v2-test")
+
+			minReplicas, _, _ := unstructured.NestedInt64(after.Object, "spec", "minReplicas")
+			Expect(minReplicas).To(Equal(int64(4)))
+			maxReplicas, _, _ := unstructured.NestedInt64(after.Object, "spec", "maxReplicas")
+			Expect(maxReplicas).To(Equal(int64(10)))
+
+			Expect(after.Object["spec"].(map[string]interface{})["metrics"]).To(Equal(before.Object["spec"].(map[string]interface{})["metrics"]))
+			Expect(after.Object["spec"].(map[string]interface{})["behavior"]).To(Equal(before.Object["spec"].(map[string]interface{})["behavior"]))
+			Expect(after.GetAnnotations()).To(Equal(before.GetAnnotations()))
+		})
+	})
+	Context("with a KEDA ScaledObject", func() {
+		It("only changes the replica counts and keeps the triggers", func() {
+			reconciler := newTestReconciler()
+
+			scaledObject := &unstructured.Unstructured{Object: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name":      "scaledobject-test",
+					"namespace": "default",
+				},
+				"spec": map[string]interface{}{
+					"scaleTargetRef": map[string]interface{}{
+						"name": "scaledobject-test",
+					},
+					"minReplicaCount": int64(1),
+					"maxReplicaCount": int64(10),
+					"triggers": []interface{}{
+						map[string]interface{}{
+							"type": "rabbitmq",
+							"metadata": map[string]interface{}{
+								"queueName": "orders",
+								"value":     "20",
+							},
+						},
+					},
+				},
+			}}
+			scaledObject.SetGroupVersionKind(scaledObjectGVK)
+			Expect(k8sClient.Create(ctx, scaledObject)).To(Succeed())
+
+			reconcileTestSPA(ctx, reconciler, newTestSPA("scaledobject-test", autoscalingv1.Resource{Name: "scaledobject-test", Type: "ScaledObject"}))
+
+			after := getTestObject(ctx, scaledObjectGVK, "scaledobject-test")
+
+			minReplicaCount, _, _ := unstructured.NestedInt64(after.Object, "spec", "minReplicaCount")
+			Expect(minReplicaCount).To(Equal(int64(4)))
+			maxReplicaCount, _, _ := unstructured.NestedInt64(after.Object, "spec", "maxReplicaCount")
+			Expect(maxReplicaCount).To(Equal(int64(10)))
+
+			Expect(after.Object["spec"].(map[string]interface{})["triggers"]).To(Equal(scaledObject.Object["spec"].(map[string]interface{})["triggers"]))
+		})
+	})
+})
@@ -0,0 +1,401 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+// ScheduledScalingGroupReconciler reconciles a ScheduledScalingGroup object - scaling its members through the helpers
+// of the ScheduledPodAutoscaler controller.
+type ScheduledScalingGroupReconciler struct {
+	*ScheduledPodAutoscalerReconciler
+}
+
+// +kubebuilder:rbac:groups=autoscaling.spa.sarmadabualkaz.io,resources=scheduledscalinggroups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=autoscaling.spa.sarmadabualkaz.io,resources=scheduledscalinggroups/status,verbs=get;update;patch
+
+var (
+	// defaultStageTimeout is used when a group has no stageTimeout set:
+	defaultStageTimeout = 10 * time.Minute
+
+	// groupMemberIndex indexes groups by their members' workloads - as <kind>/<name>:
+	groupMemberIndex = "spec.members"
+)
+
+// Reconcile rolls the active step of the group out stage by stage: the members of a stage are scaled once every
+// earlier stage is ready - at scaleUp from the members without dependencies on, at scaleDown in the reverse order.
+func (r *ScheduledScalingGroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("scheduledscalinggroup", req.NamespacedName)
+
+	// 1. Load the named ScheduledScalingGroup:
+	var group autoscalingv1.ScheduledScalingGroup
+	if err := r.Get(ctx, req.NamespacedName, &group); err != nil {
+		log.Error(err, "unable to fetch ScheduledScalingGroup")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// 2. Order the members in stages:
+	stages, err := groupStages(group.Spec.Members)
+	if err != nil {
+		log.Error(err, "invalid dependsOn of members")
+		return ctrl.Result{}, err
+	}
+
+	// 3. Work out the active step - scaleDown goes through the stages in reverse:
+	now := time.Now()
+	step, stepStart, err := activeScheduleStep(group.Spec.ScheduleCalendar, group.Spec.ScaleUp.Time, group.Spec.ScaleDown.Time, 0, now)
+	if err != nil {
+		log.Error(err, "unable to work out active step")
+		return ctrl.Result{}, err
+	}
+
+	if step == "scaleDown" {
+		for i, j := 0, len(stages)-1; i < j; i, j = i+1, j-1 {
+			stages[i], stages[j] = stages[j], stages[i]
+		}
+	}
+
+	// a new step (or a change of the members) starts the rollout over:
+	status := &group.Status
+	if status.ActiveStep != step || status.StepStartTime == nil || !status.StepStartTime.Time.Equal(stepStart) || !sameStages(status.Stages, stages) {
+		log.V(1).Info("Starting rollout of step", "step", step, "stages", len(stages))
+		status.ActiveStep = step
+		status.StepStartTime = &metav1.Time{Time: stepStart}
+		status.Stages = make([]autoscalingv1.GroupStageStatus, len(stages))
+		for i := range stages {
+			status.Stages[i] = autoscalingv1.GroupStageStatus{Members: stages[i], Phase: autoscalingv1.StagePending}
+		}
+	}
+
+	members := make(map[string]autoscalingv1.GroupMember, len(group.Spec.Members))
+	for _, member := range group.Spec.Members {
+		members[member.Name] = member
+	}
+
+	timeout := defaultStageTimeout
+	if group.Spec.StageTimeout != nil && group.Spec.StageTimeout.Duration > 0 {
+		timeout = group.Spec.StageTimeout.Duration
+	}
+
+	// 4. Scale the first stage that isn't ready yet - and wait for it. Its members are scaled again on every reconcile
+	// until it is ready (also after it timed out), so changes by hand or by an HPA in the meantime are undone:
+	var holdOff time.Duration
+	for i := range status.Stages {
+		stage := &status.Stages[i]
+		if stage.Phase == autoscalingv1.StageReady {
+			continue
+		}
+
+		for _, name := range stage.Members {
+			if err := r.scaleGroupMember(ctx, group.Namespace, members[name], step); err != nil {
+				log.Error(err, "unable to scale member", "member", name)
+				return ctrl.Result{}, err
+			}
+		}
+
+		if stage.Phase == autoscalingv1.StagePending {
+			log.V(1).Info("Scaled stage", "stage", i+1, "members", stage.Members)
+			r.Recorder.Eventf(&group, corev1.EventTypeNormal, "StageScaling", "%s stage %d of %d: scaled %s", step, i+1, len(status.Stages), strings.Join(stage.Members, ", "))
+			stage.Phase = autoscalingv1.StageScaling
+			stage.StartTime = &metav1.Time{Time: now}
+		}
+
+		var unready []string
+		for _, name := range stage.Members {
+			message, err := r.groupMemberUnready(ctx, group.Namespace, members[name], step)
+			if err != nil {
+				log.Error(err, "unable to fetch member", "member", name)
+				return ctrl.Result{}, err
+			}
+			if message != "" {
+				unready = append(unready, name+": "+message)
+			}
+		}
+
+		if len(unready) == 0 {
+			log.V(1).Info("Stage is ready", "stage", i+1, "members", stage.Members)
+			r.Recorder.Eventf(&group, corev1.EventTypeNormal, "StageReady", "%s stage %d of %d: %s ready", step, i+1, len(status.Stages), strings.Join(stage.Members, ", "))
+			stage.Phase = autoscalingv1.StageReady
+			stage.Message = ""
+			continue
+		}
+		stage.Message = strings.Join(unready, "; ")
+
+		deadline := stage.StartTime.Add(timeout)
+		if stage.Phase == autoscalingv1.StageScaling && !now.Before(deadline) {
+			log.V(1).Info("Stage didn't become ready in time - later stages are held back", "stage", i+1, "timeout", timeout)
+			r.Recorder.Eventf(&group, corev1.EventTypeWarning, "StageTimedOut", "%s stage %d of %d not ready after %s - %s", step, i+1, len(status.Stages), timeout, stage.Message)
+			stage.Phase = autoscalingv1.StageTimedOut
+		} else if stage.Phase == autoscalingv1.StageScaling {
+			holdOff = deadline.Sub(now)
+		}
+		break
+	}
+
+	// 5. Update status - reporting members that are scaled by SPAs (or other groups) too:
+	claims, err := r.scaleClaims(ctx, group.Namespace, "")
+	if err != nil {
+		log.Error(err, "unable to list SPAs and groups of the namespace")
+		return ctrl.Result{}, err
+	}
+
+	var contested []string
+	for _, member := range group.Spec.Members {
+		var others []string
+		for _, claimant := range claims[groupMemberKey(member)] {
+			if claimant != "ScheduledScalingGroup/"+group.Name {
+				others = append(others, claimant)
+			}
+		}
+		if len(others) > 0 {
+			contested = append(contested, fmt.Sprintf("%s (%s)", member.Name, strings.Join(others, ", ")))
+		}
+	}
+
+	contention := metav1.Condition{Type: autoscalingv1.ConditionContested, Status: metav1.ConditionFalse, Reason: "NoOtherSchedule",
+		Message: "no member is scaled by an SPA or another group"}
+	if len(contested) > 0 {
+		contention = metav1.Condition{Type: autoscalingv1.ConditionContested, Status: metav1.ConditionTrue, Reason: "ScaledByOtherSchedule",
+			Message: "members are scaled by an SPA or another group too: " + strings.Join(contested, "; ")}
+		if !meta.IsStatusConditionTrue(status.Conditions, autoscalingv1.ConditionContested) {
+			r.Recorder.Eventf(&group, corev1.EventTypeWarning, "ScaledByOtherSchedule", "%s", contention.Message)
+		}
+	}
+	meta.SetStatusCondition(&status.Conditions, contention)
+
+	ready := metav1.Condition{Type: autoscalingv1.ConditionReady, Status: metav1.ConditionTrue, Reason: "RolloutComplete",
+		Message: fmt.Sprintf("every stage of %s is ready", step)}
+	for i, stage := range status.Stages {
+		if stage.Phase == autoscalingv1.StageTimedOut {
+			ready = metav1.Condition{Type: autoscalingv1.ConditionReady, Status: metav1.ConditionFalse, Reason: "StageTimedOut",
+				Message: fmt.Sprintf("stage %d of %s timed out - %s", i+1, step, stage.Message)}
+			break
+		} else if stage.Phase != autoscalingv1.StageReady {
+			ready = metav1.Condition{Type: autoscalingv1.ConditionReady, Status: metav1.ConditionFalse, Reason: "RolloutInProgress",
+				Message: fmt.Sprintf("waiting for stage %d of %s", i+1, step)}
+			break
+		}
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+
+	if err := r.Status().Update(ctx, &group); err != nil {
+		log.Error(err, "unable to update ScheduledScalingGroup status")
+		return ctrl.Result{}, err
+	}
+
+	// 6. Requeue reconciliation (at the same rate as SPAs - or when a stage times out) and return to manager:
+	requeueAfter := requeueRate(log)
+	if holdOff > 0 && holdOff < requeueAfter {
+		requeueAfter = holdOff
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// groupStages orders the members in stages - every member comes in the stage after the last of the members it
+// depends on. Members of a stage are ordered by name.
+func groupStages(members []autoscalingv1.GroupMember) ([][]string, error) {
+	stageOf := make(map[string]int, len(members))
+	for _, member := range members {
+		stageOf[member.Name] = -1
+	}
+	for _, member := range members {
+		for _, dependency := range member.DependsOn {
+			if _, ok := stageOf[dependency]; !ok {
+				return nil, fmt.Errorf("member %s depends on %s which is no member of the group", member.Name, dependency)
+			}
+		}
+	}
+
+	var stages [][]string
+	for placed := 0; placed < len(members); {
+		var stage []string
+		for _, member := range members {
+			if stageOf[member.Name] >= 0 {
+				continue
+			}
+
+			ready := true
+			for _, dependency := range member.DependsOn {
+				if stageOf[dependency] < 0 {
+					ready = false
+				}
+			}
+			if ready {
+				stage = append(stage, member.Name)
+			}
+		}
+
+		if len(stage) == 0 {
+			return nil, fmt.Errorf("dependsOn of the members has a cycle")
+		}
+		for _, name := range stage {
+			stageOf[name] = len(stages)
+		}
+		sort.Strings(stage)
+
+		stages = append(stages, stage)
+		placed += len(stage)
+	}
+	return stages, nil
+}
+
+// sameStages checks whether the recorded stages hold the given members.
+func sameStages(recorded []autoscalingv1.GroupStageStatus, stages [][]string) bool {
+	if len(recorded) != len(stages) {
+		return false
+	}
+	for i := range stages {
+		if !reflect.DeepEqual(recorded[i].Members, stages[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// groupMemberTarget describes the workload of a member.
+func (r *ScheduledScalingGroupReconciler) groupMemberTarget(namespace string, member autoscalingv1.GroupMember) (*scaleTarget, error) {
+	name := member.ResourceName
+	if name == "" {
+		name = member.Name
+	}
+
+	resourceType := "deployment"
+	if member.Kind == statefulSetGVK.Kind {
+		resourceType = "statefulset"
+	}
+	return r.newScaleTarget(resourceType, autoscalingv1.Resource{Name: name}, client.ObjectKey{Name: name, Namespace: namespace})
+}
+
+// groupMemberKey returns the workload of a member as <kind>/<name>.
+func groupMemberKey(member autoscalingv1.GroupMember) string {
+	kind, name := member.Kind, member.ResourceName
+	if kind == "" {
+		kind = deploymentGVK.Kind
+	}
+	if name == "" {
+		name = member.Name
+	}
+	return kind + "/" + name
+}
+
+// groupMemberValue returns the replicas of a member for the step.
+func groupMemberValue(member autoscalingv1.GroupMember, step string) int32 {
+	if step == "scaleUp" {
+		return member.ScaleUpValue
+	}
+	return member.ScaleDownValue
+}
+
+// scaleGroupMember scales the workload of a member to its value for the step - through its /scale subresource.
+func (r *ScheduledScalingGroupReconciler) scaleGroupMember(ctx context.Context, namespace string, member autoscalingv1.GroupMember, step string) error {
+	target, err := r.groupMemberTarget(namespace, member)
+	if err != nil {
+		return err
+	}
+	return r.updateScale(ctx, target, groupMemberValue(member, step))
+}
+
+// groupMemberUnready checks whether the workload of a member runs its value for the step - and only ready pods of its
+// latest generation. It returns why it doesn't, or "" if it does.
+func (r *ScheduledScalingGroupReconciler) groupMemberUnready(ctx context.Context, namespace string, member autoscalingv1.GroupMember, step string) (string, error) {
+	target, err := r.groupMemberTarget(namespace, member)
+	if err != nil {
+		return "", err
+	}
+
+	workload := &unstructured.Unstructured{}
+	workload.SetGroupVersionKind(target.gvk)
+	if err := r.Get(ctx, target.key, workload); err != nil {
+		return "", err
+	}
+
+	value := int64(groupMemberValue(member, step))
+	specReplicas, _, _ := unstructured.NestedInt64(workload.Object, "spec", "replicas")
+	observedGeneration, _, _ := unstructured.NestedInt64(workload.Object, "status", "observedGeneration")
+	replicas, _, _ := unstructured.NestedInt64(workload.Object, "status", "replicas")
+	readyReplicas, _, _ := unstructured.NestedInt64(workload.Object, "status", "readyReplicas")
+
+	switch {
+	case specReplicas != value:
+		return fmt.Sprintf("scaled to %d instead of %d", specReplicas, value), nil
+	case observedGeneration < workload.GetGeneration():
+		return "latest generation not observed yet", nil
+	case replicas != value || readyReplicas < value:
+		return fmt.Sprintf("%d/%d ready (%d running)", readyReplicas, value, replicas), nil
+	}
+	return "", nil
+}
+
+// memberGroups maps a workload of the given kind to the groups of its namespace it is a member of - so readiness
+// changes move the rollout on right away.
+func (r *ScheduledScalingGroupReconciler) memberGroups(gvk schema.GroupVersionKind) func(client.Object) []reconcile.Request {
+	return func(obj client.Object) []reconcile.Request {
+		var groups autoscalingv1.ScheduledScalingGroupList
+		if err := r.List(context.Background(), &groups, client.InNamespace(obj.GetNamespace()), client.MatchingFields{groupMemberIndex: gvk.Kind + "/" + obj.GetName()}); err != nil {
+			r.Log.Error(err, "unable to list ScheduledScalingGroups of workload", "kind", gvk.Kind, "name", obj.GetName())
+			return nil
+		}
+
+		requests := make([]reconcile.Request, 0, len(groups.Items))
+		for _, group := range groups.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: group.Name, Namespace: group.Namespace}})
+		}
+		return requests
+	}
+}
+
+func (r *ScheduledScalingGroupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// index groups by the workloads of their members:
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &autoscalingv1.ScheduledScalingGroup{}, groupMemberIndex, func(obj client.Object) []string {
+		var keys []string
+		for _, member := range obj.(*autoscalingv1.ScheduledScalingGroup).Spec.Members {
+			keys = append(keys, groupMemberKey(member))
+		}
+		return keys
+	})
+	if err != nil {
+		return err
+	}
+
+	builder := ctrl.NewControllerManagedBy(mgr).
+		For(&autoscalingv1.ScheduledScalingGroup{})
+
+	for _, kind := range watchedTargetKinds() {
+		builder = builder.Watches(&source.Kind{Type: kind}, handler.EnqueueRequestsFromMapFunc(r.memberGroups(kind.GroupVersionKind())))
+	}
+	return builder.Complete(r)
+}
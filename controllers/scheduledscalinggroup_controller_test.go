@@ -0,0 +1,72 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var _ = Describe("ScheduledScalingGroup controller", func() {
+	member := func(name string, dependsOn ...string) autoscalingv1.GroupMember {
+		return autoscalingv1.GroupMember{Name: name, DependsOn: dependsOn}
+	}
+
+	DescribeTable("groupStages orders members in stages after the members they depend on",
+		func(members []autoscalingv1.GroupMember, expected [][]string) {
+			stages, err := groupStages(members)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stages).To(Equal(expected))
+		},
+		Entry("independent members share one stage - ordered by name", []autoscalingv1.GroupMember{member("worker"), member("api")},
+			[][]string{{"api", "worker"}}),
+		Entry("a chain", []autoscalingv1.GroupMember{member("api", "db-proxy"), member("db-proxy"), member("frontend", "api")},
+			[][]string{{"db-proxy"}, {"api"}, {"frontend"}}),
+		Entry("a member comes after the last of its dependencies", []autoscalingv1.GroupMember{member("cache"), member("db-proxy"), member("api", "db-proxy"), member("frontend", "api", "cache")},
+			[][]string{{"cache", "db-proxy"}, {"api"}, {"frontend"}}),
+		Entry("a diamond", []autoscalingv1.GroupMember{member("db"), member("api", "db"), member("worker", "db"), member("gateway", "api", "worker")},
+			[][]string{{"db"}, {"api", "worker"}, {"gateway"}}),
+		Entry("no members", []autoscalingv1.GroupMember{}, [][]string(nil)),
+	)
+
+	DescribeTable("groupStages rejects dependencies it can't order",
+		func(members []autoscalingv1.GroupMember, message string) {
+			_, err := groupStages(members)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("a member depending on itself", []autoscalingv1.GroupMember{member("api", "api")}, "cycle"),
+		Entry("two members depending on each other", []autoscalingv1.GroupMember{member("api", "worker"), member("worker", "api")}, "cycle"),
+		Entry("a cycle behind a valid stage", []autoscalingv1.GroupMember{member("db"), member("api", "db", "frontend"), member("frontend", "api")}, "cycle"),
+		Entry("a dependency that isn't a member", []autoscalingv1.GroupMember{member("api", "db")}, "depends on db which is no member"),
+	)
+
+	It("compares recorded stages with the members of the group", func() {
+		recorded := []autoscalingv1.GroupStageStatus{{Members: []string{"db"}}, {Members: []string{"api", "worker"}}}
+
+		Expect(sameStages(recorded, [][]string{{"db"}, {"api", "worker"}})).To(BeTrue())
+		Expect(sameStages(recorded, [][]string{{"db"}, {"api"}})).To(BeFalse())
+		Expect(sameStages(recorded, [][]string{{"db"}})).To(BeFalse())
+	})
+
+	It("defaults the workload of a member to a Deployment of its name", func() {
+		Expect(groupMemberKey(autoscalingv1.GroupMember{Name: "api"})).To(Equal("Deployment/api"))
+		Expect(groupMemberKey(autoscalingv1.GroupMember{Name: "db", Kind: "StatefulSet", ResourceName: "postgres"})).To(Equal("StatefulSet/postgres"))
+	})
+})
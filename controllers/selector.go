@@ -0,0 +1,246 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+// syncTargetStatuses matches status.targets to the selected targets: an entry is added for every new target and
+// the entries of targets that are gone (or no longer match the selector) are dropped. It returns the status entry
+// of every target - in the order of targets - along with the names of the added and removed targets.
+func syncTargetStatuses(status *autoscalingv1.ScheduledPodAutoscalerStatus, targets []*scaleTarget) (entries []*autoscalingv1.TargetStatus, added []string, removed []string) {
+	existing := make(map[string]autoscalingv1.NamedTargetStatus, len(status.Targets))
+	for _, entry := range status.Targets {
+		existing[entry.Name] = entry
+	}
+
+	kept := make([]autoscalingv1.NamedTargetStatus, 0, len(targets))
+	for _, target := range targets {
+		entry, ok := existing[target.key.Name]
+		if !ok {
+			entry = autoscalingv1.NamedTargetStatus{Name: target.key.Name}
+			added = append(added, target.key.Name)
+		}
+		delete(existing, target.key.Name)
+		kept = append(kept, entry)
+	}
+
+	for name := range existing {
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+
+	status.Targets = kept
+	for i := range status.Targets {
+		entries = append(entries, &status.Targets[i].TargetStatus)
+	}
+	return entries, added, removed
+}
+
+// summarizeContention sets the Contested condition of the SPA from the Contested conditions of its selected targets.
+func summarizeContention(status *autoscalingv1.ScheduledPodAutoscalerStatus) {
+	var contested []string
+	for _, entry := range status.Targets {
+		if meta.IsStatusConditionTrue(entry.Conditions, autoscalingv1.ConditionContested) {
+			contested = append(contested, entry.Name)
+		}
+	}
+
+	if len(contested) == 0 {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    autoscalingv1.ConditionContested,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NoCompetingWriter",
+			Message: "none of the selected resources is written by another field manager",
+		})
+		return
+	}
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    autoscalingv1.ConditionContested,
+		Status:  metav1.ConditionTrue,
+		Reason:  "CompetingWriter",
+		Message: fmt.Sprintf("%d of %d selected resources are written by another field manager: %s", len(contested), len(status.Targets), strings.Join(contested, ", ")),
+	})
+}
+
+// selectingScheduledPodAutoscalers returns a map func mapping a created, changed or deleted resource of the given kind to
+// the SPAs in its namespace targeting that kind whose selector matches it - or whose status still lists it, so they notice
+// it is gone (or no longer matches) - and to the Hibernate mode SPAs of its namespace (for the hibernated kinds).
+func (r *ScheduledPodAutoscalerReconciler) selectingScheduledPodAutoscalers(gvk schema.GroupVersionKind) handler.MapFunc {
+	hibernated := false
+	for _, kind := range hibernatedKinds {
+		hibernated = hibernated || kind.GroupKind() == gvk.GroupKind()
+	}
+
+	return func(obj client.Object) []reconcile.Request {
+		var scheduledPodAutoscalers autoscalingv1.ScheduledPodAutoscalerList
+		if err := r.List(context.Background(), &scheduledPodAutoscalers, client.InNamespace(obj.GetNamespace())); err != nil {
+			r.Log.Error(err, "unable to list ScheduledPodAutoscalers", "namespace", obj.GetNamespace())
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, spa := range scheduledPodAutoscalers.Items {
+			// a sleeping namespace puts workloads created in it to sleep right away:
+			if spa.Spec.Mode == autoscalingv1.ModeHibernate {
+				if hibernated {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: spa.Name, Namespace: spa.Namespace}})
+				}
+				continue
+			}
+
+			if spa.Spec.Resource.Selector == nil || !r.targetsKind(&spa, gvk) {
+				continue
+			}
+
+			selector, err := metav1.LabelSelectorAsSelector(spa.Spec.Resource.Selector)
+			if err != nil {
+				continue
+			}
+
+			if selector.Matches(labels.Set(obj.GetLabels())) || selectedBy(&spa, obj.GetName()) {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: spa.Name, Namespace: spa.Namespace}})
+			}
+		}
+		return requests
+	}
+}
+
+// targetsKind checks whether the targets of the SPA are of the given kind.
+func (r *ScheduledPodAutoscalerReconciler) targetsKind(spa *autoscalingv1.ScheduledPodAutoscaler, gvk schema.GroupVersionKind) bool {
+	resourceType, err := normalizedResourceType(spa)
+	if err != nil || resourceType == "hibernate" {
+		return false
+	}
+
+	target, err := r.newScaleTarget(resourceType, spa.Spec.Resource, client.ObjectKey{Namespace: spa.Namespace})
+	return err == nil && target.gvk.GroupKind() == gvk.GroupKind()
+}
+
+// selectedBy checks whether the SPA has a status entry for a target of the given name.
+func selectedBy(spa *autoscalingv1.ScheduledPodAutoscaler, name string) bool {
+	for _, entry := range spa.Status.Targets {
+		if entry.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// watchedTargetKinds are the workload kinds whose changes are mapped to linking SPAs and scaling groups right away.
+func watchedTargetKinds() []*unstructured.Unstructured {
+	var kinds []*unstructured.Unstructured
+	for _, gvk := range []schema.GroupVersionKind{deploymentGVK, statefulSetGVK} {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(gvk)
+		kinds = append(kinds, u)
+	}
+	return kinds
+}
+
+// selectableTargetKinds are the kinds whose changes are mapped to selecting SPAs right away: the workloads, HPAs and -
+// if KEDA is installed when the controller starts - ScaledObjects (otherwise they are picked up with the regular requeue).
+// Selectors can't be combined with apiVersion/kind, so no other kinds are selected.
+func (r *ScheduledPodAutoscalerReconciler) selectableTargetKinds() []*unstructured.Unstructured {
+	kinds := watchedTargetKinds()
+
+	if mapping, err := r.Mapper.RESTMapping(hpaGroupKind, hpaVersions...); err == nil {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(mapping.GroupVersionKind)
+		kinds = append(kinds, u)
+	}
+
+	if _, err := r.Mapper.RESTMapping(scaledObjectGVK.GroupKind(), scaledObjectGVK.Version); err == nil {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(scaledObjectGVK)
+		kinds = append(kinds, u)
+	} else {
+		r.Log.V(1).Info("KEDA ScaledObjects aren't served - selectors of ScaledObjects are only picked up with the regular requeue")
+	}
+	return kinds
+}
+
+// withoutOverriddenTargets drops the targets of an SPA created from a ClusterScheduledPodAutoscaler that are scaled by
+// a namespaced SPA with overrideClusterPolicies - matched by name (or by labels for a selector) whatever their type,
+// as e.g. an HPA usually shares its name with the Deployment it scales. It returns the kept targets along with the
+// names of the dropped ones.
+func (r *ScheduledPodAutoscalerReconciler) withoutOverriddenTargets(ctx context.Context, namespace string, targets []*scaleTarget) ([]*scaleTarget, []string, error) {
+	var scheduledPodAutoscalers autoscalingv1.ScheduledPodAutoscalerList
+	if err := r.List(ctx, &scheduledPodAutoscalers, client.InNamespace(namespace)); err != nil {
+		return nil, nil, err
+	}
+
+	var overriding []autoscalingv1.ScheduledPodAutoscaler
+	for _, spa := range scheduledPodAutoscalers.Items {
+		if _, fromClusterPolicy := spa.Labels[clusterPolicyLabel]; spa.Spec.OverrideClusterPolicies && !fromClusterPolicy {
+			overriding = append(overriding, spa)
+		}
+	}
+	if len(overriding) == 0 {
+		return targets, nil, nil
+	}
+
+	kept := make([]*scaleTarget, 0, len(targets))
+	var dropped []string
+	for _, target := range targets {
+		if overriddenBy(overriding, target) {
+			dropped = append(dropped, target.key.Name)
+		} else {
+			kept = append(kept, target)
+		}
+	}
+	return kept, dropped, nil
+}
+
+// overriddenBy checks whether any of the SPAs scales a resource with the name (or labels) of the target.
+func overriddenBy(scheduledPodAutoscalers []autoscalingv1.ScheduledPodAutoscaler, target *scaleTarget) bool {
+	var targetLabels labels.Set
+	if target.object != nil {
+		targetLabels = target.object.GetLabels()
+	}
+
+	for _, spa := range scheduledPodAutoscalers {
+		if spa.Spec.Resource.Name == target.key.Name {
+			return true
+		}
+
+		if spa.Spec.Resource.Selector == nil || targetLabels == nil {
+			continue
+		}
+		if selector, err := metav1.LabelSelectorAsSelector(spa.Spec.Resource.Selector); err == nil && selector.Matches(targetLabels) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+var _ = Describe("Selected targets", func() {
+	int32Ptr := func(i int32) *int32 { return &i }
+
+	newTargets := func(names ...string) []*scaleTarget {
+		var targets []*scaleTarget
+		for _, name := range names {
+			targets = append(targets, &scaleTarget{key: client.ObjectKey{Name: name, Namespace: "default"}})
+		}
+		return targets
+	}
+
+	It("adds entries for new targets and drops those of targets that are gone - keeping the others", func() {
+		status := &autoscalingv1.ScheduledPodAutoscalerStatus{Targets: []autoscalingv1.NamedTargetStatus{
+			{Name: "api", TargetStatus: autoscalingv1.TargetStatus{LastAppliedReplicas: int32Ptr(4)}},
+			{Name: "worker", TargetStatus: autoscalingv1.TargetStatus{LastAppliedReplicas: int32Ptr(2)}},
+			{Name: "cron", TargetStatus: autoscalingv1.TargetStatus{LastAppliedReplicas: int32Ptr(1)}},
+		}}
+
+		entries, added, removed := syncTargetStatuses(status, newTargets("api", "web"))
+
+		Expect(added).To(Equal([]string{"web"}))
+		Expect(removed).To(Equal([]string{"cron", "worker"}))
+		Expect(status.Targets).To(HaveLen(2))
+		Expect(status.Targets[0].Name).To(Equal("api"))
+		Expect(status.Targets[1].Name).To(Equal("web"))
+
+		// the entries point into status - in the order of the targets:
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].LastAppliedReplicas).To(Equal(int32Ptr(4)))
+		Expect(entries[1].LastAppliedReplicas).To(BeNil())
+		entries[1].LastAppliedReplicas = int32Ptr(3)
+		Expect(status.Targets[1].LastAppliedReplicas).To(Equal(int32Ptr(3)))
+	})
+
+	It("changes nothing while the same targets are selected", func() {
+		status := &autoscalingv1.ScheduledPodAutoscalerStatus{Targets: []autoscalingv1.NamedTargetStatus{{Name: "api"}}}
+
+		_, added, removed := syncTargetStatuses(status, newTargets("api"))
+		Expect(added).To(BeEmpty())
+		Expect(removed).To(BeEmpty())
+		Expect(status.Targets).To(HaveLen(1))
+	})
+
+	It("drops every entry once nothing is selected", func() {
+		status := &autoscalingv1.ScheduledPodAutoscalerStatus{Targets: []autoscalingv1.NamedTargetStatus{{Name: "api"}}}
+
+		entries, _, removed := syncTargetStatuses(status, nil)
+		Expect(entries).To(BeEmpty())
+		Expect(removed).To(Equal([]string{"api"}))
+		Expect(status.Targets).To(BeEmpty())
+	})
+
+	It("summarizes the contested targets", func() {
+		contested := metav1.Condition{Type: autoscalingv1.ConditionContested, Status: metav1.ConditionTrue, Reason: "CompetingWriter"}
+		status := &autoscalingv1.ScheduledPodAutoscalerStatus{Targets: []autoscalingv1.NamedTargetStatus{
+			{Name: "api", TargetStatus: autoscalingv1.TargetStatus{Conditions: []metav1.Condition{contested}}},
+			{Name: "web"},
+		}}
+
+		summarizeContention(status)
+		Expect(meta.IsStatusConditionTrue(status.Conditions, autoscalingv1.ConditionContested)).To(BeTrue())
+		Expect(meta.FindStatusCondition(status.Conditions, autoscalingv1.ConditionContested).Message).To(ContainSubstring("1 of 2"))
+
+		status.Targets[0].Conditions = nil
+		summarizeContention(status)
+		Expect(meta.IsStatusConditionFalse(status.Conditions, autoscalingv1.ConditionContested)).To(BeTrue())
+	})
+})
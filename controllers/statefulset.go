@@ -0,0 +1,118 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	// criticalVolumeAnnotation marks a StatefulSet PVC whose ordinal must never be scaled away:
+	criticalVolumeAnnotation = "spa.sarmadabualkaz.io/critical"
+
+	statefulSetGVK = schema.GroupVersionKind{
+		Group:   "apps",
+		Kind:    "StatefulSet",
+		Version: "v1",
+	}
+
+	pvcListGVK = schema.GroupVersionKind{
+		Group:   "",
+		Kind:    "PersistentVolumeClaimList",
+		Version: "v1",
+	}
+)
+
+// statefulSetScaleDownStep returns the replica count to apply next when scaling a StatefulSet down to scaleValue.
+// StatefulSets with the Parallel pod management policy would remove every surplus pod at once, so they are
+// stepped down one ordinal at a time - waiting for the highest ordinal to be gone before removing the next one.
+// OrderedReady StatefulSets already do this themselves and get scaleValue right away.
+func statefulSetScaleDownStep(target *scaleTarget, scaleValue int32) (next int32, waiting bool) {
+	current := target.replicas()
+	if current == nil || scaleValue >= *current {
+		return scaleValue, false
+	}
+
+	policy, _, _ := unstructured.NestedString(target.object.Object, "spec", "podManagementPolicy")
+	if policy != "Parallel" {
+		return scaleValue, false
+	}
+
+	// status.replicas still counts the pod of the previous step while it is terminating:
+	statusReplicas, _, _ := unstructured.NestedInt64(target.object.Object, "status", "replicas")
+	if statusReplicas > int64(*current) {
+		return *current, true
+	}
+	return *current - 1, false
+}
+
+// criticalVolumeReplicas returns the lowest replica count that keeps every pod whose PVC is
+// annotated as critical - i.e. the highest critical ordinal plus one.
+func (r *ScheduledPodAutoscalerReconciler) criticalVolumeReplicas(ctx context.Context, target *scaleTarget) (int32, error) {
+	templates, _, err := unstructured.NestedSlice(target.object.Object, "spec", "volumeClaimTemplates")
+	if err != nil || len(templates) == 0 {
+		return 0, err
+	}
+
+	pvcs := &unstructured.UnstructuredList{}
+	pvcs.SetGroupVersionKind(pvcListGVK)
+
+	if err := r.List(ctx, pvcs, client.InNamespace(target.key.Namespace)); err != nil {
+		return 0, err
+	}
+
+	var floor int32
+	for _, pvc := range pvcs.Items {
+		if pvc.GetAnnotations()[criticalVolumeAnnotation] != "true" {
+			continue
+		}
+
+		for _, template := range templates {
+			templateSpec, ok := template.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			templateName, _, _ := unstructured.NestedString(templateSpec, "metadata", "name")
+
+			if ordinal, ok := volumeOrdinal(pvc.GetName(), templateName, target.key.Name); ok && ordinal+1 > floor {
+				floor = ordinal + 1
+			}
+		}
+	}
+	return floor, nil
+}
+
+// volumeOrdinal returns the ordinal of the pod a PVC of the StatefulSet's volumeClaimTemplate belongs to - volumes of
+// a StatefulSet are named <template>-<statefulset>-<ordinal>. It is false for PVCs of other templates or StatefulSets.
+func volumeOrdinal(pvcName string, templateName string, statefulSetName string) (int32, bool) {
+	prefix := templateName + "-" + statefulSetName + "-"
+	if !strings.HasPrefix(pvcName, prefix) {
+		return 0, false
+	}
+
+	ordinal, err := strconv.ParseInt(strings.TrimPrefix(pvcName, prefix), 10, 32)
+	if err != nil || ordinal < 0 {
+		return 0, false
+	}
+	return int32(ordinal), true
+}
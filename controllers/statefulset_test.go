@@ -0,0 +1,68 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("StatefulSet targets", func() {
+	newStatefulSetTarget := func(policy string, replicas int64, statusReplicas int64) *scaleTarget {
+		return &scaleTarget{
+			resourceType: "statefulset",
+			scaledField:  []string{"spec", "replicas"},
+			gvk:          statefulSetGVK,
+			object: &unstructured.Unstructured{Object: map[string]interface{}{
+				"spec":   map[string]interface{}{"replicas": replicas, "podManagementPolicy": policy},
+				"status": map[string]interface{}{"replicas": statusReplicas},
+			}},
+		}
+	}
+
+	DescribeTable("statefulSetScaleDownStep steps Parallel StatefulSets down one ordinal at a time",
+		func(policy string, replicas int64, statusReplicas int64, scaleValue int32, expectedNext int32, expectedWaiting bool) {
+			next, waiting := statefulSetScaleDownStep(newStatefulSetTarget(policy, replicas, statusReplicas), scaleValue)
+			Expect(next).To(Equal(expectedNext))
+			Expect(waiting).To(Equal(expectedWaiting))
+		},
+		Entry("OrderedReady goes straight to the value", "OrderedReady", int64(5), int64(5), int32(2), int32(2), false),
+		Entry("no policy is OrderedReady", "", int64(5), int64(5), int32(2), int32(2), false),
+		Entry("Parallel removes the highest ordinal", "Parallel", int64(5), int64(5), int32(2), int32(4), false),
+		Entry("Parallel waits for the last ordinal to be gone", "Parallel", int64(4), int64(5), int32(2), int32(4), true),
+		Entry("Parallel takes the last step", "Parallel", int64(3), int64(3), int32(2), int32(2), false),
+		Entry("scale-ups aren't stepped", "Parallel", int64(2), int64(2), int32(5), int32(5), false),
+		Entry("at the value already", "Parallel", int64(2), int64(2), int32(2), int32(2), false),
+	)
+
+	DescribeTable("volumeOrdinal parses the ordinal of a StatefulSet volume",
+		func(pvcName string, expectedOrdinal int32, expectedOk bool) {
+			ordinal, ok := volumeOrdinal(pvcName, "data", "db")
+			Expect(ok).To(Equal(expectedOk))
+			Expect(ordinal).To(Equal(expectedOrdinal))
+		},
+		Entry("first ordinal", "data-db-0", int32(0), true),
+		Entry("two digit ordinal", "data-db-12", int32(12), true),
+		Entry("another template", "logs-db-3", int32(0), false),
+		Entry("another StatefulSet with the same prefix", "data-db-replica-3", int32(0), false),
+		Entry("no ordinal", "data-db-", int32(0), false),
+		Entry("negative ordinal", "data-db--1", int32(0), false),
+		Entry("unrelated volume", "scratch", int32(0), false),
+	)
+})
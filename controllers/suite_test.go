@@ -54,7 +54,11 @@ var _ = BeforeSuite(func(done Done) {
 
 	By("bootstrapping test environment")
 	testEnv = &envtest.Environment{
-		CRDDirectoryPaths: []string{filepath.Join("..", "config", "crd", "bases")},
+		CRDDirectoryPaths: []string{
+			filepath.Join("..", "config", "crd", "bases"),
+			// stub CRDs of third-party autoscalers (e.g. KEDA):
+			filepath.Join("..", "config", "crd", "test"),
+		},
 	}
 
 	var err error
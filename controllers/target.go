@@ -0,0 +1,298 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	kautoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1 "spa.sarmadabualkaz.io/spa/api/v1"
+)
+
+// scaledObjectGVK is the KEDA ScaledObject - accessed as unstructured so KEDA isn't needed to build the controller:
+var scaledObjectGVK = schema.GroupVersionKind{
+	Group:   "keda.sh",
+	Kind:    "ScaledObject",
+	Version: "v1alpha1",
+}
+
+// scaleTarget is the resource an SPA scales, as last read from the API server.
+type scaleTarget struct {
+	// resourceType is the normalized spec.resource.type - deployment, statefulset, hpa, hpaOperator,
+	// scaledObject or scale (for resources referenced by apiVersion/kind):
+	resourceType string
+
+	// scaledField is the path of the field the scheduled value is written to (e.g. spec.replicas) - nil if there is none:
+	scaledField []string
+
+	// maxField is the path of the field holding the upper replica bound of autoscalers - nil if there is none:
+	maxField []string
+
+	gvk schema.GroupVersionKind
+	key client.ObjectKey
+
+	// groupResource is set for resources scaled through their /scale subresource:
+	groupResource schema.GroupResource
+
+	// object is the resource itself - nil for resources only accessed through their /scale subresource:
+	object *unstructured.Unstructured
+
+	// scale is the /scale subresource of resources referenced by apiVersion/kind:
+	scale *kautoscalingv1.Scale
+}
+
+// normalizedResourceType returns the resource type of the SPA the targets are handled by - deployment, statefulset,
+// scaledObject, hpaOperator (annotatedDeployment), hpa, scale (for resources referenced by apiVersion/kind) or hibernate.
+func normalizedResourceType(spa *autoscalingv1.ScheduledPodAutoscaler) (string, error) {
+	passedResourceType := spa.Spec.Resource.Type
+
+	if spa.Spec.Mode == autoscalingv1.ModeHibernate {
+		return "hibernate", nil
+	} else if spa.Spec.Resource.Kind != "" {
+		return "scale", nil
+	} else if (passedResourceType == "deployment") || (passedResourceType == "Deployment") {
+		return "deployment", nil
+	} else if (passedResourceType == "statefulset") || (passedResourceType == "StatefulSet") || (passedResourceType == "statefulSet") {
+		return "statefulset", nil
+	} else if (passedResourceType == "scaledObject") || (passedResourceType == "ScaledObject") {
+		return "scaledObject", nil
+	} else if (passedResourceType == "annotatedDeployment") || (passedResourceType == "AnnotatedDeployment") {
+		return "hpaOperator", nil
+	} else if (passedResourceType == "HPA") || (passedResourceType == "hpa") || (passedResourceType == "HorizontalPodAutoscaler") || (passedResourceType == "horizontalPodAutoscaler") {
+		return "hpa", nil
+	}
+	return "", fmt.Errorf("unrecognizable resource.type %s ResourceType", passedResourceType)
+}
+
+// getScaleTarget fetches the resource of the given (normalized) resource type.
+func (r *ScheduledPodAutoscalerReconciler) getScaleTarget(ctx context.Context, resourceType string, resource autoscalingv1.Resource, namespace string) (*scaleTarget, error) {
+	target, err := r.newScaleTarget(resourceType, resource, client.ObjectKey{Name: resource.Name, Namespace: namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	if resourceType == "scale" {
+		scale, err := r.ScaleClient.Scales(namespace).Get(ctx, target.groupResource, resource.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		target.scale = scale
+		return target, nil
+	}
+
+	target.object = &unstructured.Unstructured{}
+	target.object.SetGroupVersionKind(target.gvk)
+
+	if err := r.Get(ctx, target.key, target.object); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// listScaleTargets fetches every resource of the given (normalized) resource type matching resource.selector
+// in the namespace - ordered by name.
+func (r *ScheduledPodAutoscalerReconciler) listScaleTargets(ctx context.Context, resourceType string, resource autoscalingv1.Resource, namespace string) ([]*scaleTarget, error) {
+	selector, err := metav1.LabelSelectorAsSelector(resource.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := r.newScaleTarget(resourceType, resource, client.ObjectKey{Namespace: namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(template.gvk.GroupVersion().WithKind(template.gvk.Kind + "List"))
+
+	if err := r.List(ctx, list, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+
+	targets := make([]*scaleTarget, 0, len(list.Items))
+	for i := range list.Items {
+		target := *template
+		target.key.Name = list.Items[i].GetName()
+		target.object = &list.Items[i]
+		targets = append(targets, &target)
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].key.Name < targets[j].key.Name })
+	return targets, nil
+}
+
+// newScaleTarget describes the resource of the given (normalized) resource type without fetching it.
+func (r *ScheduledPodAutoscalerReconciler) newScaleTarget(resourceType string, resource autoscalingv1.Resource, key client.ObjectKey) (*scaleTarget, error) {
+	target := &scaleTarget{resourceType: resourceType, key: key}
+
+	switch resourceType {
+	case "deployment":
+		target.gvk = deploymentGVK
+		target.scaledField = []string{"spec", "replicas"}
+	case "statefulset":
+		target.gvk = statefulSetGVK
+		target.scaledField = []string{"spec", "replicas"}
+	case "hpaOperator":
+		// the HPA-operator creates and updates the HPA of a Deployment based on its annotations:
+		minReplicasAnnotation, maxReplicasAnnotation := hpaOperatorAnnotations(resource)
+		target.gvk = deploymentGVK
+		target.scaledField = []string{"metadata", "annotations", minReplicasAnnotation}
+		target.maxField = []string{"metadata", "annotations", maxReplicasAnnotation}
+	case "hpa":
+		mapping, err := r.Mapper.RESTMapping(hpaGroupKind, hpaVersions...)
+		if err != nil {
+			return nil, err
+		}
+		target.gvk = mapping.GroupVersionKind
+		target.scaledField = []string{"spec", "minReplicas"}
+		target.maxField = []string{"spec", "maxReplicas"}
+	case "scaledObject":
+		target.gvk = scaledObjectGVK
+		target.scaledField = []string{"spec", "minReplicaCount"}
+		target.maxField = []string{"spec", "maxReplicaCount"}
+	case "scale":
+		gv, err := schema.ParseGroupVersion(resource.APIVersion)
+		if err != nil {
+			return nil, err
+		}
+		target.gvk = gv.WithKind(resource.Kind)
+		target.scaledField = []string{"spec", "replicas"}
+	default:
+		return nil, fmt.Errorf("unrecognizable resource.type %s ResourceType", resourceType)
+	}
+
+	// Deployments, StatefulSets and resources referenced by apiVersion/kind are written through /scale:
+	if resourceType == "deployment" || resourceType == "statefulset" || resourceType == "scale" {
+		mapping, err := r.Mapper.RESTMapping(target.gvk.GroupKind(), target.gvk.Version)
+		if err != nil {
+			return nil, err
+		}
+		target.groupResource = mapping.Resource.GroupResource()
+	}
+	return target, nil
+}
+
+// String describes the target for logs and events, e.g. "deployment test-deployment".
+func (t *scaleTarget) String() string {
+	return fmt.Sprintf("%s %s", strings.ToLower(t.gvk.Kind), t.key.Name)
+}
+
+// replicas returns the current value of the scaled field - nil if there is none.
+func (t *scaleTarget) replicas() *int32 {
+	if t.scale != nil {
+		replicas := t.scale.Spec.Replicas
+		return &replicas
+	}
+	return t.readField(t.scaledField)
+}
+
+// maxReplicas returns the current upper replica bound of autoscalers - nil if there is none.
+func (t *scaleTarget) maxReplicas() *int32 {
+	return t.readField(t.maxField)
+}
+
+// fieldName returns the dotted path of the scaled field, e.g. "spec.replicas".
+func (t *scaleTarget) fieldName() string {
+	return strings.Join(t.scaledField, ".")
+}
+
+// readField returns the replica count held by the field at path - annotations hold it as a string.
+func (t *scaleTarget) readField(path []string) *int32 {
+	if t.object == nil || path == nil {
+		return nil
+	}
+
+	value, found, err := unstructured.NestedFieldNoCopy(t.object.Object, path...)
+	if err != nil || !found {
+		return nil
+	}
+
+	var replicas int32
+	switch value := value.(type) {
+	case int64:
+		replicas = int32(value)
+	case string:
+		parsed, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return nil
+		}
+		replicas = int32(parsed)
+	default:
+		return nil
+	}
+	return &replicas
+}
+
+// setReplicaField sets the field at path to the given replica count - as a string for annotations.
+func setReplicaField(u *unstructured.Unstructured, path []string, replicas int32) error {
+	if path[0] == "metadata" {
+		return unstructured.SetNestedField(u.Object, strconv.Itoa(int(replicas)), path...)
+	}
+	return unstructured.SetNestedField(u.Object, int64(replicas), path...)
+}
+
+// managedFields returns the managedFields of the resource - nil if only its /scale subresource is known.
+func (t *scaleTarget) managedFields() []metav1.ManagedFieldsEntry {
+	if t.object == nil {
+		return nil
+	}
+	return t.object.GetManagedFields()
+}
+
+// updateScale sets the replicas of the target through its /scale subresource.
+// The scale is re-read on every attempt, so resourceVersion conflicts are retried against the latest version - it
+// isn't written if it already has the replicas.
+func (r *ScheduledPodAutoscalerReconciler) updateScale(ctx context.Context, target *scaleTarget, replicas int32) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		scale, err := r.ScaleClient.Scales(target.key.Namespace).Get(ctx, target.groupResource, target.key.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if scale.Spec.Replicas == replicas {
+			return nil
+		}
+		scale.Spec.Replicas = replicas
+
+		_, err = r.ScaleClient.Scales(target.key.Namespace).Update(ctx, target.groupResource, scale, metav1.UpdateOptions{FieldManager: fieldManager})
+		return err
+	})
+}
+
+// hpaOperatorAnnotations returns the Deployment annotations the HPA-operator reads minReplicas and maxReplicas from.
+func hpaOperatorAnnotations(resource autoscalingv1.Resource) (minReplicasAnnotation string, maxReplicasAnnotation string) {
+	minReplicasAnnotation = autoscalingv1.DefaultMinReplicasAnnotation
+	if resource.MinReplicasAnnotation != "" {
+		minReplicasAnnotation = resource.MinReplicasAnnotation
+	}
+
+	maxReplicasAnnotation = autoscalingv1.DefaultMaxReplicasAnnotation
+	if resource.MaxReplicasAnnotation != "" {
+		maxReplicasAnnotation = resource.MaxReplicasAnnotation
+	}
+	return minReplicasAnnotation, maxReplicasAnnotation
+}
@@ -21,7 +21,10 @@ import (
 	"os"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/scale"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
@@ -43,8 +46,14 @@ func init() {
 
 func main() {
 	var metricsAddr string
+	var enableScheduleAnnotations bool
+	var placeholderImage string
 	// var enableLeaderElection bool
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.BoolVar(&enableScheduleAnnotations, "enable-schedule-annotations", false,
+		"Apply schedules declared by the spa.sarmadabualkaz.io/schedule annotation of Deployments and HPAs.")
+	flag.StringVar(&placeholderImage, "placeholder-image", "",
+		"The image run by placeholder pods - e.g. the pause image of a registry mirror (default registry.k8s.io/pause:3.9).")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
@@ -59,20 +68,98 @@ func main() {
 		os.Exit(1)
 	}
 
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create discovery client")
+		os.Exit(1)
+	}
+
+	scaleClient, err := scale.NewForConfig(mgr.GetConfig(), mgr.GetRESTMapper(), dynamic.LegacyAPIPathResolverFunc, scale.NewDiscoveryScaleKindResolver(discoveryClient))
+	if err != nil {
+		setupLog.Error(err, "unable to create scale client")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.ScheduledPodAutoscalerReconciler{
+		Client:      mgr.GetClient(),
+		Log:         ctrl.Log.WithName("controllers").WithName("ScheduledPodAutoscaler"),
+		Scheme:      mgr.GetScheme(),
+		Recorder:    mgr.GetEventRecorderFor("scheduledpodautoscaler-controller"),
+		APIReader:   mgr.GetAPIReader(),
+		Mapper:      mgr.GetRESTMapper(),
+		ScaleClient: scaleClient,
+
+		PlaceholderImage: placeholderImage,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ScheduledPodAutoscaler")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.ClusterScheduledPodAutoscalerReconciler{
 		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("ScheduledPodAutoscaler"),
+		Log:    ctrl.Log.WithName("controllers").WithName("ClusterScheduledPodAutoscaler"),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "ScheduledPodAutoscaler")
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterScheduledPodAutoscaler")
 		os.Exit(1)
 	}
 
+	if err = (&controllers.ScheduledScalingGroupReconciler{
+		ScheduledPodAutoscalerReconciler: &controllers.ScheduledPodAutoscalerReconciler{
+			Client:      mgr.GetClient(),
+			Log:         ctrl.Log.WithName("controllers").WithName("ScheduledScalingGroup"),
+			Scheme:      mgr.GetScheme(),
+			Recorder:    mgr.GetEventRecorderFor("scheduledscalinggroup-controller"),
+			APIReader:   mgr.GetAPIReader(),
+			Mapper:      mgr.GetRESTMapper(),
+			ScaleClient: scaleClient,
+		},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ScheduledScalingGroup")
+		os.Exit(1)
+	}
+
+	if enableScheduleAnnotations {
+		for _, resourceType := range []string{"deployment", "hpa"} {
+			if err = (&controllers.ScheduleAnnotationReconciler{
+				ScheduledPodAutoscalerReconciler: &controllers.ScheduledPodAutoscalerReconciler{
+					Client:      mgr.GetClient(),
+					Log:         ctrl.Log.WithName("controllers").WithName("ScheduleAnnotation"),
+					Scheme:      mgr.GetScheme(),
+					Recorder:    mgr.GetEventRecorderFor("scheduleannotation-controller"),
+					APIReader:   mgr.GetAPIReader(),
+					Mapper:      mgr.GetRESTMapper(),
+					ScaleClient: scaleClient,
+				},
+				ResourceType: resourceType,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "ScheduleAnnotation", "type", resourceType)
+				os.Exit(1)
+			}
+		}
+	}
+
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
 		if err = (&autoscalingv1.ScheduledPodAutoscaler{}).SetupWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "ScheduledPodAutoscaler")
 			os.Exit(1)
 		}
+		if err = (&autoscalingv1.ScheduleTemplate{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ScheduleTemplate")
+			os.Exit(1)
+		}
+		if err = (&autoscalingv1.ClusterScheduleTemplate{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ClusterScheduleTemplate")
+			os.Exit(1)
+		}
+		if err = (&autoscalingv1.ClusterScheduledPodAutoscaler{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ClusterScheduledPodAutoscaler")
+			os.Exit(1)
+		}
+		if err = (&autoscalingv1.ScheduledScalingGroup{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ScheduledScalingGroup")
+			os.Exit(1)
+		}
 	}
 
 	// +kubebuilder:scaffold:builder